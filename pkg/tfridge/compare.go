@@ -0,0 +1,110 @@
+package tfridge
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	changeAdded      = "added"
+	changeRemoved    = "removed"
+	changeUpdated    = "updated"
+	changeNewVersion = "new_version"
+)
+
+// reportDelta lists what changed between a previous JSON report and the
+// current scan.
+type reportDelta struct {
+	Previous string         `json:"previous"`
+	Root     string         `json:"root"`
+	Changes  []reportChange `json:"changes"`
+}
+
+// reportChange is a single difference between two reports. For an updated
+// dependency From and To are the declared versions, for a new version they
+// are the latest version in the previous and the current report.
+type reportChange struct {
+	Type   string `json:"type"`
+	Source string `json:"source"`
+	Change string `json:"change"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+}
+
+// compareReports returns the modules and providers added and removed since
+// prev, those whose declared version changed and those for which a newer
+// upgrade became available.
+func compareReports(path string, prev, cur Report) reportDelta {
+	delta := reportDelta{
+		Previous: path,
+		Root:     cur.Root,
+		Changes:  []reportChange{},
+	}
+	delta.Changes = append(delta.Changes, compareResults(kindModule, prev.Modules, cur.Modules)...)
+	delta.Changes = append(delta.Changes, compareResults(kindProvider, prev.Providers, cur.Providers)...)
+	return delta
+}
+
+// compareResults compares the results of one kind, sorted by source.
+func compareResults(kind string, prev, cur []Result) []reportChange {
+	previous := make(map[string]Result)
+	for _, res := range prev {
+		previous[res.Source] = res
+	}
+
+	var changes []reportChange
+	for _, res := range cur {
+		old, ok := previous[res.Source]
+		delete(previous, res.Source)
+		if !ok {
+			changes = append(changes, reportChange{Type: kind, Source: res.Source, Change: changeAdded, To: res.Current})
+			continue
+		}
+		if res.Current != old.Current {
+			changes = append(changes, reportChange{Type: kind, Source: res.Source, Change: changeUpdated, From: old.Current, To: res.Current})
+		}
+		if res.Error == "" && res.Outdated && res.Latest != old.Latest {
+			changes = append(changes, reportChange{Type: kind, Source: res.Source, Change: changeNewVersion, From: old.Latest, To: res.Latest})
+		}
+	}
+	for _, old := range previous {
+		changes = append(changes, reportChange{Type: kind, Source: old.Source, Change: changeRemoved, From: old.Current})
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Source < changes[j].Source
+	})
+	return changes
+}
+
+// message describes the change for the text report.
+func (c reportChange) message() string {
+	switch c.Change {
+	case changeAdded:
+		return fmt.Sprintf("Added %s %s %s", c.Type, c.Source, c.To)
+	case changeRemoved:
+		return fmt.Sprintf("Removed %s %s %s", c.Type, c.Source, c.From)
+	case changeUpdated:
+		return fmt.Sprintf("Updated %s %s: %s -> %s", c.Type, c.Source, c.From, c.To)
+	default:
+		if c.From == "" {
+			return fmt.Sprintf("New version of %s %s: %s", c.Type, c.Source, c.To)
+		}
+		return fmt.Sprintf("New version of %s %s: %s -> %s", c.Type, c.Source, c.From, c.To)
+	}
+}
+
+// renderDelta prints the changes since the previous report.
+func renderDelta(w io.Writer, delta reportDelta) {
+	if len(delta.Changes) == 0 {
+		fmt.Fprintf(w, "No changes since %s\n\n", delta.Previous)
+		return
+	}
+
+	fmt.Fprintf(w, "Changes since %s:\n", delta.Previous)
+	for _, c := range delta.Changes {
+		fmt.Fprintf(w, "  %s\n", c.message())
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,175 @@
+package tfridge
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/urfave/cli/v2"
+	"github.com/urfave/cli/v2/altsrc"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileName is the name of the configuration file looked up in the scan
+// root and the home directory.
+const configFileName = ".tfridge.yaml"
+
+// fileConfig is the contents of a tfridge configuration file. Besides the
+// sections below, any top-level key named after a command line flag sets the
+// default value of that flag.
+type fileConfig struct {
+	// Targets maps a module or provider source to the version it is planned
+	// to be upgraded to.
+	Targets map[string]string `yaml:"targets"`
+
+	// Ignore lists module and provider sources that are not checked at all.
+	Ignore []string `yaml:"ignore"`
+
+	// Pin maps a module or provider source to an accepted version. Newer
+	// releases are not reported as outdated.
+	Pin map[string]string `yaml:"pin"`
+}
+
+// ignored reports whether a dependency is listed in the ignore section, by
+// its address or any of the sources it is declared with.
+func (c *fileConfig) ignored(dep *dependency) bool {
+	for _, ignore := range c.Ignore {
+		if ignore == dep.Source {
+			return true
+		}
+		for _, source := range dep.declaredSources() {
+			if ignore == source {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pinned returns the accepted version of a dependency from the pin section,
+// looked up by its address or any of the sources it is declared with.
+func (c *fileConfig) pinned(dep *dependency) string {
+	if version, ok := c.Pin[dep.Source]; ok {
+		return version
+	}
+	for _, source := range dep.declaredSources() {
+		if version, ok := c.Pin[source]; ok {
+			return version
+		}
+	}
+	return ""
+}
+
+// removeIgnored drops the dependencies listed in the ignore section from the
+// scan, so they are neither looked up nor reported.
+func removeIgnored(result *scanResult, cfg *fileConfig) {
+	for _, deps := range []map[string]*dependency{result.moduleMap, result.providerMap} {
+		for key, dep := range deps {
+			if cfg.ignored(dep) {
+				delete(deps, key)
+			}
+		}
+	}
+}
+
+// applyPins records the pinned version of every result from the pin section.
+func applyPins(results []lookupResult, cfg *fileConfig) {
+	for i := range results {
+		results[i].Pinned = cfg.pinned(results[i].Dependency)
+	}
+}
+
+// findConfig returns the path of the configuration file in rootPath, falling
+// back to the one in the home directory, or an empty string if there is none.
+func findConfig(rootPath string) string {
+	dirs := []string{rootPath}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, home)
+	}
+
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		if path := filepath.Join(dir, configFileName); pathExists(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// commandConfig returns the configuration file of a command line: the one
+// given with --config, or else the one findConfig finds for the scanned
+// directory, which is the directory of the file read with --from-state or
+// --from-modules-json when one is given.
+func commandConfig(c *cli.Context) string {
+	if path := c.String("config"); path != "" {
+		return path
+	}
+	dir := c.Args().First()
+	for _, name := range []string{"from-state", "from-modules-json"} {
+		if input := c.String(name); input != "" {
+			dir = filepath.Dir(input)
+		}
+	}
+	return findConfig(dir)
+}
+
+// loadConfig reads and validates the configuration file at path. An empty
+// path yields an empty configuration.
+func loadConfig(path string) (*fileConfig, error) {
+	cfg := &fileConfig{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for source, target := range cfg.Targets {
+		if _, err := semver.NewVersion(target); err != nil {
+			return nil, fmt.Errorf("%s: invalid target version %q for %s: %w", path, target, source, err)
+		}
+	}
+	for source, pin := range cfg.Pin {
+		if _, err := semver.NewVersion(pin); err != nil {
+			return nil, fmt.Errorf("%s: invalid pinned version %q for %s: %w", path, pin, source, err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// levelThresholdFlag is --fail-on-outdated, which the configuration file may
+// set either to true or to a level, as on the command line. altsrc can only
+// set generic flags from values that already are a cli.Generic.
+type levelThresholdFlag struct {
+	*cli.GenericFlag
+}
+
+// ApplyInputSourceValue implements altsrc.FlagInputSourceExtension.
+func (f *levelThresholdFlag) ApplyInputSourceValue(c *cli.Context, isc altsrc.InputSourceContext) error {
+	if c.IsSet(f.Name) {
+		return nil
+	}
+
+	value, err := isc.String(f.Name)
+	if err != nil {
+		enabled, boolErr := isc.Bool(f.Name)
+		if boolErr != nil {
+			return err
+		}
+		value = strconv.FormatBool(enabled)
+	}
+	if value == "" {
+		return nil
+	}
+	return c.Set(f.Name, value)
+}
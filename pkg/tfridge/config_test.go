@@ -0,0 +1,102 @@
+package tfridge
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigFileSetsFlags(t *testing.T) {
+	registry := newFakeRegistry(t, map[string]string{
+		"/v1/modules/terraform-aws-modules/vpc/aws/versions": `{"modules":[{"versions":[{"version":"5.0.0"},{"version":"5.2.0"}]}]}`,
+	})
+	registry.useAsDefault(t)
+
+	output := filepath.Join(t.TempDir(), "report.txt")
+	tests := []struct {
+		config string
+		args   []string
+		want   int
+	}{
+		{"fail-on-outdated: true", nil, 1},
+		{"fail-on-outdated: minor", nil, 1},
+		{"fail-on-outdated: major", nil, 0},
+		{"fail-on-outdated: false", nil, 0},
+		{"fail-on-outdated: major", []string{"--fail-on-outdated"}, 1},
+		{"fail-on-outdated: true", []string{"--fail-on-outdated=false"}, 0},
+	}
+	for _, tt := range tests {
+		root := writeFiles(t, map[string]string{
+			"main.tf": `
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.0.0"
+}
+`,
+			configFileName: tt.config + "\noutput: " + output + "\n",
+		})
+		os.Remove(output)
+
+		args := append(tt.args, root)
+		if code := exitCode(runApp(t, args...)); code != tt.want {
+			t.Errorf("%q with %q: exited with status %d, want %d", tt.config, tt.args, code, tt.want)
+		}
+
+		report, err := os.ReadFile(output)
+		if err != nil {
+			t.Errorf("%q: the report was not written to the output set in the configuration file: %v", tt.config, err)
+		} else if !strings.Contains(string(report), "Latest version: 5.2.0") {
+			t.Errorf("%q: the report written to %s is incomplete:\n%s", tt.config, output, report)
+		}
+	}
+}
+
+func TestConfigFileSetsWatch(t *testing.T) {
+	newFakeRegistry(t, nil).useAsDefault(t)
+	root := writeFiles(t, map[string]string{
+		"modules.json": `{"Modules":[]}`,
+		configFileName: "watch: true\n",
+	})
+
+	err := runApp(t, "--config", filepath.Join(root, configFileName), "--from-modules-json", filepath.Join(root, "modules.json"))
+	if err == nil || !strings.Contains(err.Error(), "--watch cannot be combined") {
+		t.Errorf("got %v, want --watch from the configuration file to be rejected with --from-modules-json", err)
+	}
+}
+
+func TestConfigFileRejectsInvalidLevel(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"main.tf":      "",
+		configFileName: "fail-on-outdated: huge\n",
+	})
+
+	if err := runApp(t, "--parse-only", root); err == nil {
+		t.Error("an unknown fail-on-outdated level in the configuration file was accepted")
+	}
+}
+
+func TestConfigFileNextToModulesJSON(t *testing.T) {
+	registry := newFakeRegistry(t, map[string]string{
+		"/v1/modules/terraform-aws-modules/vpc/aws/versions": `{"modules":[{"versions":[{"version":"5.0.0"},{"version":"5.2.0"}]}]}`,
+	})
+	registry.useAsDefault(t)
+
+	output := filepath.Join(t.TempDir(), "report.json")
+	root := writeFiles(t, map[string]string{
+		".terraform/modules/modules.json":      `{"Modules":[{"Key":"vpc","Source":"registry.terraform.io/terraform-aws-modules/vpc/aws","Version":"5.0.0"}]}`,
+		".terraform/modules/" + configFileName: "format: json\nfail-on-outdated: true\noutput: " + output + "\n",
+	})
+
+	err := runApp(t, "--from-modules-json", filepath.Join(root, ".terraform", "modules", "modules.json"))
+	if code := exitCode(err); code != 1 {
+		t.Errorf("exited with status %d, want 1 from fail-on-outdated in the configuration file next to modules.json", code)
+	}
+	doc, err := readReport(output)
+	if err != nil {
+		t.Fatalf("the JSON report was not written to the output set in the configuration file: %v", err)
+	}
+	if len(doc.Modules) != 1 || doc.Modules[0].Latest != "5.2.0" {
+		t.Errorf("got modules %+v, want terraform-aws-modules/vpc/aws at latest 5.2.0", doc.Modules)
+	}
+}
@@ -0,0 +1,112 @@
+package tfridge
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// defaultReleasesURL is the base URL of the HashiCorp releases API.
+const defaultReleasesURL = "https://releases.hashicorp.com"
+
+// terraformReleases is the index of Terraform CLI releases.
+type terraformReleases struct {
+	Versions map[string]struct {
+		Version string `json:"version"`
+	} `json:"versions"`
+}
+
+// LatestTerraformVersion returns the newest stable release of the Terraform CLI.
+func (c *Client) LatestTerraformVersion(ctx context.Context) (string, error) {
+	url := fmt.Sprintf("%s/terraform/index.json", c.ReleasesURL)
+
+	var releases terraformReleases
+	if err := c.getJSON(ctx, url, &releases, "failed to fetch Terraform releases"); err != nil {
+		return "", err
+	}
+
+	var latest *semver.Version
+	for v := range releases.Versions {
+		version, err := semver.NewVersion(v)
+		if err != nil || version.Prerelease() != "" {
+			continue
+		}
+		if latest == nil || version.GreaterThan(latest) {
+			latest = version
+		}
+	}
+
+	if latest == nil {
+		return "", ErrNoVersions
+	}
+	return latest.String(), nil
+}
+
+// lookupCoreVersion compares every distinct required_version constraint found
+// in the scan against the latest Terraform release, which is fetched once.
+func lookupCoreVersion(ctx context.Context, client *Client, result *scanResult) []lookupResult {
+	if len(result.coreMap) == 0 || ctx.Err() != nil {
+		return nil
+	}
+
+	latestVersion, err := client.LatestTerraformVersion(ctx)
+
+	var results []lookupResult
+	for _, dep := range sortedDependencies(result.coreMap) {
+		results = append(results, newLookupResult(kindCore, dep, latestVersion, err))
+	}
+	return results
+}
+
+// coreStatus describes whether the floor of a required_version constraint is
+// behind the latest Terraform release.
+func coreStatus(r lookupResult) string {
+	floor := lowerBound(r.Dependency.Version)
+	switch {
+	case r.NotFound:
+		return "No stable Terraform release found"
+	case floor == nil:
+		return "Core version floor unknown"
+	case isOutdated(r.Dependency.Version, r.Latest):
+		return fmt.Sprintf("Core version floor %s is behind the latest stable release", floor)
+	default:
+		return fmt.Sprintf("Core version floor %s is at the latest stable release", floor)
+	}
+}
+
+// coreProtocolSupport lists the Terraform releases supporting each provider
+// plugin protocol major version, from Since up to but excluding Until.
+var coreProtocolSupport = []struct {
+	Protocol int
+	Since    *semver.Version
+	Until    *semver.Version
+}{
+	{Protocol: 4, Until: semver.MustParse("0.12.0")},
+	{Protocol: 5, Since: semver.MustParse("0.12.0")},
+	{Protocol: 6, Since: semver.MustParse("0.15.4")},
+}
+
+// coreProtocols returns the provider plugin protocol major versions that a
+// Terraform release supports.
+func coreProtocols(core *semver.Version) []int {
+	var protocols []int
+	for _, p := range coreProtocolSupport {
+		if (p.Since == nil || !core.LessThan(p.Since)) && (p.Until == nil || core.LessThan(p.Until)) {
+			protocols = append(protocols, p.Protocol)
+		}
+	}
+	return protocols
+}
+
+// minCoreVersion returns the oldest Terraform release allowed by any
+// required_version constraint found in the scan, or nil if there is none.
+func minCoreVersion(result *scanResult) *semver.Version {
+	var min *semver.Version
+	for _, dep := range result.coreMap {
+		if floor := lowerBound(dep.Version); floor != nil && (min == nil || floor.LessThan(min)) {
+			min = floor
+		}
+	}
+	return min
+}
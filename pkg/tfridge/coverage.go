@@ -0,0 +1,93 @@
+package tfridge
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// coverageCategories lists the categories a dependency can be counted under,
+// in the order they are reported.
+var coverageCategories = []string{
+	"resolved",
+	"not_found",
+	"lookup_failed",
+	"local",
+	"remote",
+	"dynamic",
+	"unsupported",
+	"skipped",
+}
+
+// Coverage counts how many dependencies could be resolved to a latest
+// version, and why the others could not.
+type Coverage struct {
+	Total    int            `json:"total"`
+	Resolved int            `json:"resolved"`
+	Counts   map[string]int `json:"counts"`
+}
+
+// coverageCategory returns the category a result is counted under.
+func coverageCategory(r lookupResult) string {
+	if r.Skipped {
+		return "skipped"
+	}
+	if r.Kind == kindModule {
+		switch sourceKind(r.Dependency.lookupSource()) {
+		case sourceLocal:
+			return "local"
+		case sourceRemote:
+			if _, _, ok := githubRepo(r.Dependency.lookupSource()); !ok {
+				return "remote"
+			}
+		case sourceDynamic:
+			return "dynamic"
+		case sourceInvalid:
+			return "unsupported"
+		}
+	}
+
+	switch {
+	case r.Err != nil:
+		return "lookup_failed"
+	case r.NotFound:
+		return "not_found"
+	default:
+		return "resolved"
+	}
+}
+
+// computeCoverage categorizes every module and provider result.
+func computeCoverage(results []lookupResult) *Coverage {
+	cov := &Coverage{Counts: make(map[string]int)}
+	for _, category := range coverageCategories {
+		cov.Counts[category] = 0
+	}
+
+	for _, r := range results {
+		category := coverageCategory(r)
+		cov.Counts[category]++
+		cov.Total++
+	}
+	cov.Resolved = cov.Counts["resolved"]
+
+	return cov
+}
+
+// percent returns n as a percentage of the total.
+func (c *Coverage) percent(n int) float64 {
+	if c.Total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(c.Total)
+}
+
+// renderCoverage prints the coverage summary.
+func renderCoverage(w io.Writer, c *Coverage) {
+	fmt.Fprintf(w, "Coverage: %d of %d dependencies resolved (%.1f%%)\n", c.Resolved, c.Total, c.percent(c.Resolved))
+	for _, category := range coverageCategories {
+		label := strings.ReplaceAll(category, "_", " ") + ":"
+		fmt.Fprintf(w, "  %-14s %3d (%.1f%%)\n", label, c.Counts[category], c.percent(c.Counts[category]))
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,205 @@
+package tfridge
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// mixedFixture declares a dependency of every coverage category but skipped.
+var mixedFixture = map[string]string{
+	"main.tf": `
+terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "5.0.0"
+    }
+  }
+}
+
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.2.0"
+}
+
+module "tagged" {
+  source = "github.com/org/repo?ref=v1.2.0"
+}
+
+module "empty" {
+  source  = "acme/empty/aws"
+  version = "1.0.0"
+}
+
+module "missing" {
+  source  = "acme/missing/aws"
+  version = "1.0.0"
+}
+
+module "local" {
+  source = "./modules/local"
+}
+
+module "bucket" {
+  source = "s3::https://s3.amazonaws.com/bucket/module.zip"
+}
+
+module "dynamic" {
+  source = "${var.registry}/vpc/aws"
+}
+`,
+}
+
+// mixedRoutes are the registry and GitHub responses for mixedFixture.
+var mixedRoutes = map[string]string{
+	"/v1/modules/terraform-aws-modules/vpc/aws/versions": `{"modules":[{"versions":[{"version":"5.2.0"}]}]}`,
+	"/v1/modules/acme/empty/aws/versions":                `{"modules":[{"versions":[]}]}`,
+	"/v1/providers/hashicorp/aws/versions":               `{"versions":[{"version":"5.0.0"},{"version":"6.1.0"}]}`,
+	"/repos/org/repo/tags":                               `[{"name":"v1.2.0"},{"name":"v1.3.0"}]`,
+}
+
+func TestComputeCoverage(t *testing.T) {
+	registry := newFakeRegistry(t, mixedRoutes)
+	root := writeFiles(t, mixedFixture)
+	result, err := scanDirectory(root, pathFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cov := computeCoverage(lookupLatestVersions(context.Background(), registry.client(), result, newProgress(0, true), nil))
+
+	want := map[string]int{
+		"resolved":      3,
+		"not_found":     1,
+		"lookup_failed": 1,
+		"local":         1,
+		"remote":        1,
+		"dynamic":       1,
+		"unsupported":   0,
+		"skipped":       0,
+	}
+	if cov.Total != 8 || cov.Resolved != 3 {
+		t.Errorf("got %d of %d resolved, want 3 of 8", cov.Resolved, cov.Total)
+	}
+	for category, n := range want {
+		if cov.Counts[category] != n {
+			t.Errorf("got %d %s, want %d", cov.Counts[category], category, n)
+		}
+	}
+
+	if got := cov.percent(cov.Resolved); got != 37.5 {
+		t.Errorf("got %.2f%% resolved, want 37.5%%", got)
+	}
+	if got := cov.percent(cov.Counts["local"]); got != 12.5 {
+		t.Errorf("got %.2f%% local, want 12.5%%", got)
+	}
+
+	var out bytes.Buffer
+	renderCoverage(&out, cov)
+	for _, line := range []string{
+		"Coverage: 3 of 8 dependencies resolved (37.5%)",
+		"  not found:       1 (12.5%)",
+		"  unsupported:     0 (0.0%)",
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("coverage summary is missing %q:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestCoverageIgnoresLevel(t *testing.T) {
+	registry := newFakeRegistry(t, mixedRoutes)
+	registry.useAsDefault(t)
+	root := writeFiles(t, mixedFixture)
+
+	output := filepath.Join(t.TempDir(), "report.json")
+	if err := runApp(t, "--coverage", "--level", "major", "--format", "json", "-o", output, root); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := readReport(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(doc.Providers) != 1 {
+		t.Errorf("got %d results with --level major, want only the major provider update", len(doc.Modules)+len(doc.Providers))
+	}
+	if doc.Coverage == nil || doc.Coverage.Total != 8 || doc.Coverage.Resolved != 3 {
+		t.Errorf("got coverage %+v with --level major, want 3 of all 8 dependencies resolved", doc.Coverage)
+	}
+}
+
+func TestUnregisteredModulesAreNotLookedUp(t *testing.T) {
+	registry := newFakeRegistry(t, nil)
+	root := writeFiles(t, map[string]string{
+		"main.tf": `
+module "local" {
+  source = "./modules/local"
+}
+
+module "git" {
+  source = "git::https://gitlab.com/org/network.git?ref=v1.0.0"
+}
+
+module "bucket" {
+  source = "s3::https://s3.amazonaws.com/bucket/module.zip"
+}
+`,
+	})
+	result, err := scanDirectory(root, pathFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := lookupLatestVersions(context.Background(), registry.client(), result, newProgress(0, true), nil)
+	if n := registry.requestCount(); n != 0 {
+		t.Errorf("made %d requests, want none for local and non-GitHub remote modules", n)
+	}
+
+	var out bytes.Buffer
+	for _, r := range results {
+		if !r.NotChecked || r.Err != nil {
+			t.Errorf("%s: got not checked %v, error %v; want not checked without an error", r.Dependency.Source, r.NotChecked, r.Err)
+		}
+		renderResult(&out, r)
+	}
+	for _, line := range []string{
+		"Latest version: not checked (local module)",
+		"Latest version: not checked (not in a registry)",
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("report is missing %q:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestFailOnErrorIgnoresUnregisteredModules(t *testing.T) {
+	newFakeRegistry(t, nil).useAsDefault(t)
+	unregistered := `
+module "local" {
+  source = "./modules/local"
+}
+
+module "bucket" {
+  source = "s3::https://s3.amazonaws.com/bucket/module.zip"
+}
+`
+	root := writeFiles(t, map[string]string{"main.tf": unregistered})
+	output := filepath.Join(t.TempDir(), "report.txt")
+	if err := runApp(t, "--fail-on-error", "-o", output, root); err != nil {
+		t.Errorf("--fail-on-error failed for local and S3 modules: %v", err)
+	}
+
+	root = writeFiles(t, map[string]string{"main.tf": unregistered + `
+module "missing" {
+  source  = "acme/missing/aws"
+  version = "1.0.0"
+}
+`})
+	if code := exitCode(runApp(t, "--fail-on-error", "-o", output, root)); code != 1 {
+		t.Errorf("--fail-on-error exited with status %d for a module the registry failed to look up, want 1", code)
+	}
+}
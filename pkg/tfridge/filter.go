@@ -0,0 +1,81 @@
+package tfridge
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// pathFilter restricts a scan to the files matching the include patterns, if
+// there are any, and not matching the exclude patterns. Patterns are matched
+// against slash-separated paths relative to the scanned directory and may use
+// "**" to match any number of directories.
+type pathFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// validate checks that every pattern is well formed.
+func (f pathFilter) validate() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		for _, segment := range strings.Split(pattern, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// excludesDir reports whether every file below the directory rel is excluded,
+// so that the directory does not need to be walked.
+func (f pathFilter) excludesDir(rel string) bool {
+	return rel != "." && matchAny(f.Exclude, rel)
+}
+
+// allows reports whether the file rel should be scanned.
+func (f pathFilter) allows(rel string) bool {
+	if len(f.Include) > 0 && !matchAny(f.Include, rel) {
+		return false
+	}
+	return !matchAny(f.Exclude, rel)
+}
+
+// matchAny reports whether rel, or any directory it is in, matches one of the
+// patterns, so that a pattern naming a directory applies to everything in it.
+func matchAny(patterns []string, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range patterns {
+		for name := rel; name != "." && name != "/"; name = path.Dir(name) {
+			if matchGlob(strings.Split(pattern, "/"), strings.Split(name, "/")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchGlob matches the segments of a path against the segments of a
+// pattern, where a "**" segment matches zero or more path segments.
+func matchGlob(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchGlob(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
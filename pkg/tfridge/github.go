@@ -0,0 +1,131 @@
+package tfridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// defaultGitHubURL is the base URL of the GitHub REST API.
+const defaultGitHubURL = "https://api.github.com"
+
+// githubTag is a single entry of the /repos/:owner/:repo/tags response.
+type githubTag struct {
+	Name string `json:"name"`
+}
+
+// linkNextRegex extracts the URL of the next page from a Link header.
+var linkNextRegex = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// githubRepo returns the owner and repository of a module source hosted on
+// GitHub, such as "github.com/org/repo//subdir?ref=v1.2.0" or
+// "git::https://github.com/org/repo.git?ref=v1.2.0".
+func githubRepo(source string) (string, string, bool) {
+	if moduleHost(source, defaultRegistryHost) != "github.com" {
+		return "", "", false
+	}
+
+	address := moduleAddress(source)
+	if i := strings.Index(address, "::"); i >= 0 {
+		address = address[i+len("::"):]
+	}
+
+	var repoPath string
+	if strings.HasPrefix(address, "git@github.com:") {
+		repoPath = strings.TrimPrefix(address, "git@github.com:")
+	} else if i := strings.Index(address, "github.com/"); i >= 0 {
+		repoPath = address[i+len("github.com/"):]
+	}
+
+	parts := strings.Split(repoPath, "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], strings.TrimSuffix(parts[1], ".git"), true
+}
+
+// gitRef returns the ref a module source is pinned to with "?ref=", or an
+// empty string if there is none.
+func gitRef(source string) string {
+	i := strings.Index(source, "?")
+	if i < 0 {
+		return ""
+	}
+	query, err := url.ParseQuery(source[i+1:])
+	if err != nil {
+		return ""
+	}
+	return query.Get("ref")
+}
+
+// LatestGitHubTag returns the highest semantic version tag of a GitHub
+// repository, as it is spelled in the repository, following pagination links.
+// It returns ErrNoVersions if the repository has no such tag.
+func (c *Client) LatestGitHubTag(ctx context.Context, owner, repo string) (string, error) {
+	releases, _, err := c.githubReleases(ctx, owner, repo)
+	if err != nil {
+		return "", err
+	}
+	return releases[0].Original(), nil
+}
+
+// githubReleases returns the semantic version tags of a GitHub repository
+// that are not prereleases, and every semantic version tag, both sorted from
+// newest to oldest.
+func (c *Client) githubReleases(ctx context.Context, owner, repo string) (releases, published []*semver.Version, err error) {
+	if err := c.checkHost("github.com"); err != nil {
+		return nil, nil, err
+	}
+
+	next := fmt.Sprintf("%s/repos/%s/%s/tags?per_page=100", c.GitHubURL, owner, repo)
+	for next != "" {
+		resp, err := c.get(ctx, next)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var tags []githubTag
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("failed to fetch GitHub tags, status code: %d", resp.StatusCode)
+		} else {
+			err = json.NewDecoder(resp.Body).Decode(&tags)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, tag := range tags {
+			version, err := semver.NewVersion(tag.Name)
+			if err != nil {
+				continue
+			}
+			published = append(published, version)
+			if version.Prerelease() == "" {
+				releases = append(releases, version)
+			}
+		}
+
+		next = ""
+		if match := linkNextRegex.FindStringSubmatch(resp.Header.Get("Link")); match != nil {
+			next = match[1]
+		}
+	}
+
+	for _, versions := range [][]*semver.Version{releases, published} {
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i].GreaterThan(versions[j])
+		})
+	}
+	if len(releases) == 0 {
+		return nil, published, ErrNoVersions
+	}
+	return releases, published, nil
+}
@@ -0,0 +1,77 @@
+package tfridge
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+)
+
+// dirGroup is the modules and providers declared in a single directory, as
+// reported by --group-by dir.
+type dirGroup struct {
+	Dir     string
+	Results []lookupResult
+}
+
+// groupKey identifies a dependency of one kind within a directory.
+type groupKey struct {
+	Dir  string
+	Kind string
+}
+
+// groupByDir splits the results by the directory, relative to root, of each
+// of their declarations, sorted by directory. Within a directory a result
+// only has that directory's declarations, so its current version is the one
+// declared there. Results below min are then dropped, as are directories
+// left without results.
+func groupByDir(root string, results []lookupResult, scan *scanResult, min string) []dirGroup {
+	deps := make(map[groupKey]map[string]*dependency)
+	grouped := make(map[string][]lookupResult)
+
+	for _, r := range results {
+		for _, decl := range r.Dependency.Declarations {
+			for _, dir := range scan.dirsOf(decl.File) {
+				key := groupKey{Dir: dir, Kind: r.Kind}
+				if deps[key] == nil {
+					deps[key] = make(map[string]*dependency)
+				}
+
+				_, seen := deps[key][r.Dependency.Source]
+				addDeclaration(deps[key], r.Dependency.Source, decl)
+				if !seen {
+					dep := deps[key][r.Dependency.Source]
+					dep.Lookup = r.Dependency.Lookup
+					r := r
+					r.Dependency = dep
+					grouped[dir] = append(grouped[dir], r)
+				}
+			}
+		}
+	}
+
+	var groups []dirGroup
+	for dir, results := range grouped {
+		results = filterLevel(results, min)
+		if len(results) == 0 {
+			continue
+		}
+		if rel, err := filepath.Rel(root, dir); err == nil {
+			dir = rel
+		}
+		groups = append(groups, dirGroup{Dir: dir, Results: results})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Dir < groups[j].Dir
+	})
+	return groups
+}
+
+// renderGroups prints a section per directory, rendering its results with
+// render.
+func renderGroups(w io.Writer, groups []dirGroup, render func(io.Writer, []lookupResult)) {
+	for _, group := range groups {
+		fmt.Fprintf(w, "Directory: %s\n\n", group.Dir)
+		render(w, group.Results)
+	}
+}
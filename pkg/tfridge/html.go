@@ -0,0 +1,165 @@
+package tfridge
+
+import (
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+// htmlPage is the data the HTML report is rendered from.
+type htmlPage struct {
+	Root      string
+	Generated string
+	Version   string
+	Sections  []htmlSection
+	Summary   Summary
+}
+
+// htmlSection is a table of the modules or providers of a report, or of a
+// single directory with --group-by dir.
+type htmlSection struct {
+	Title string
+	Rows  []htmlRow
+}
+
+// htmlRow is a single module or provider in an HTML table.
+type htmlRow struct {
+	Source  string
+	Current string
+	Latest  string
+	Status  string
+	Class   string
+	Error   string
+}
+
+// htmlTemplate is a self-contained page, with inline CSS and a small script
+// that sorts a table by the column whose header is clicked.
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>tfridge report: {{.Root}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.4em; margin-bottom: 0.2em; }
+.meta { color: #666; margin-top: 0; }
+table { border-collapse: collapse; margin-bottom: 2em; min-width: 60%; }
+th, td { border: 1px solid #ddd; padding: 0.4em 0.8em; text-align: left; }
+th { background: #f4f4f4; cursor: pointer; user-select: none; }
+th::after { content: " \2195"; color: #aaa; }
+td.current { background: #e6f4ea; }
+td.minor { background: #fff4ce; }
+td.major { background: #fde2e1; }
+td.failed { background: #eee; color: #666; }
+</style>
+</head>
+<body>
+<h1>tfridge report: {{.Root}}</h1>
+<p class="meta">Generated {{.Generated}} by tfridge {{.Version}}. {{.Summary.Total}} dependencies, {{.Summary.Outdated}} outdated, {{.Summary.Unpinned}} unpinned, {{.Summary.Errored}} failed lookups.</p>
+{{range .Sections}}
+<h2>{{.Title}}</h2>
+<table class="sortable">
+<thead><tr><th>Source</th><th>Current</th><th>Latest</th><th>Status</th></tr></thead>
+<tbody>
+{{- range .Rows}}
+<tr><td>{{.Source}}</td><td>{{.Current}}</td><td>{{.Latest}}</td><td{{with .Class}} class="{{.}}"{{end}}{{with .Error}} title="{{.}}"{{end}}>{{.Status}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+{{end}}
+<script>
+document.querySelectorAll("table.sortable").forEach(function (table) {
+  table.querySelectorAll("th").forEach(function (th, column) {
+    var ascending = true;
+    th.addEventListener("click", function () {
+      var body = table.tBodies[0];
+      var rows = Array.prototype.slice.call(body.rows);
+      rows.sort(function (a, b) {
+        var x = a.cells[column].textContent, y = b.cells[column].textContent;
+        return (ascending ? 1 : -1) * x.localeCompare(y, undefined, {numeric: true});
+      });
+      ascending = !ascending;
+      rows.forEach(function (row) { body.appendChild(row); });
+    });
+  });
+});
+</script>
+</body>
+</html>
+`))
+
+// renderHTML writes the report as a self-contained HTML page with a sortable
+// table of the modules and one of the providers, or a pair of tables per
+// directory with --group-by dir. The status cells are colored like the
+// "Latest version" line of the text report.
+func renderHTML(w io.Writer, rep *report, now time.Time) error {
+	page := htmlPage{
+		Root:      rep.RootPath,
+		Generated: now.Format(time.RFC1123),
+		Version:   appVersion,
+		Summary:   newReport(rep).Summary,
+	}
+
+	if rep.GroupByDir {
+		for _, group := range rep.Groups {
+			page.Sections = append(page.Sections, htmlSections(group.Dir+": ", group.Results)...)
+		}
+	} else {
+		page.Sections = htmlSections("", rep.Results)
+	}
+
+	return htmlTemplate.Execute(w, page)
+}
+
+// htmlSections returns a section for the modules and one for the providers
+// among results, leaving out a kind without results.
+func htmlSections(prefix string, results []lookupResult) []htmlSection {
+	var sections []htmlSection
+	for _, kind := range []string{kindModule, kindProvider} {
+		section := htmlSection{Title: prefix + kindLabel(kind) + "s"}
+		for _, r := range results {
+			if r.Kind == kind {
+				section.Rows = append(section.Rows, newHTMLRow(r))
+			}
+		}
+		if len(section.Rows) > 0 {
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
+// newHTMLRow converts a result into a table row.
+func newHTMLRow(r lookupResult) htmlRow {
+	status, _ := tableStatus(r)
+	row := htmlRow{
+		Source:  strings.Join(r.Dependency.declaredSources(), ", "),
+		Current: orDash(r.Dependency.Version),
+		Latest:  orDash(r.Latest),
+		Status:  status,
+		Class:   statusClass(r),
+	}
+	if r.Err != nil {
+		row.Error = r.Err.Error()
+	}
+	return row
+}
+
+// statusClass returns the CSS class a result's status cell is colored with.
+func statusClass(r lookupResult) string {
+	switch {
+	case r.Err != nil:
+		return "failed"
+	case r.Skipped, r.Dynamic, r.NotChecked, r.NotFound, r.unpinned():
+		return ""
+	case r.level() == levelMajor:
+		return "major"
+	case r.level() != "":
+		return "minor"
+	case lowerBound(r.Dependency.Version) != nil:
+		return "current"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,222 @@
+package tfridge
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Report is the outcome of a scan, as returned by ScanDir and written by
+// --format json.
+type Report struct {
+	Root string `json:"root"`
+
+	// Outdated is set if any module or provider is outdated, and Summary
+	// counts them, so that scripts need not go through every result.
+	Outdated bool    `json:"outdated"`
+	Summary  Summary `json:"summary"`
+
+	// Offline is set by --offline, in which case no latest versions were
+	// looked up and nothing is reported as outdated.
+	Offline bool `json:"offline,omitempty"`
+
+	Modules   []Result   `json:"modules"`
+	Providers []Result   `json:"providers"`
+	Core      []Result   `json:"core,omitempty"`
+	Coverage  *Coverage  `json:"coverage,omitempty"`
+	Plan      []PlanStep `json:"plan,omitempty"`
+
+	HostViolations []Location `json:"host_violations,omitempty"`
+
+	// Directories is set by --group-by dir, with the modules and providers
+	// declared in each directory.
+	Directories []Directory `json:"directories,omitempty"`
+}
+
+// Summary counts the modules and providers in a report.
+type Summary struct {
+	Total    int `json:"total"`
+	Outdated int `json:"outdated"`
+	Unpinned int `json:"unpinned"`
+	Errored  int `json:"errored"`
+}
+
+// Directory is the modules and providers declared in a single directory.
+type Directory struct {
+	Dir       string   `json:"dir"`
+	Modules   []Result `json:"modules"`
+	Providers []Result `json:"providers"`
+}
+
+// PlanStep is a single upgrade in the remediation plan.
+type PlanStep struct {
+	Step    int    `json:"step"`
+	Level   string `json:"level"`
+	Kind    string `json:"kind"`
+	Source  string `json:"source"`
+	Current string `json:"current"`
+	Latest  string `json:"latest"`
+}
+
+// Result is a single module or provider in a report.
+type Result struct {
+	Source       string     `json:"source"`
+	Lookup       string     `json:"lookup,omitempty"`
+	Current      string     `json:"current"`
+	Latest       string     `json:"latest,omitempty"`
+	Pinned       string     `json:"pinned,omitempty"`
+	Outdated     bool       `json:"outdated"`
+	Unpinned     bool       `json:"unpinned,omitempty"`
+	NotFound     bool       `json:"not_found,omitempty"`
+	Skipped      bool       `json:"skipped,omitempty"`
+	Dynamic      bool       `json:"dynamic,omitempty"`
+	NotChecked   bool       `json:"not_checked,omitempty"`
+	Level        string     `json:"level,omitempty"`
+	Behind       int        `json:"releases_behind,omitempty"`
+	Unpublished  bool       `json:"unpublished,omitempty"`
+	Suggestion   string     `json:"suggestion,omitempty"`
+	BlockedBy    string     `json:"blocked_by,omitempty"`
+	Error        string     `json:"error,omitempty"`
+	Declarations []Location `json:"declarations"`
+}
+
+// Location is a place a module or provider is declared.
+type Location struct {
+	Source  string `json:"source"`
+	Version string `json:"version"`
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+}
+
+// newReport converts a report into its JSON representation.
+func newReport(rep *report) Report {
+	doc := Report{
+		Root:      rep.RootPath,
+		Offline:   rep.Offline,
+		Modules:   []Result{},
+		Providers: []Result{},
+		Coverage:  rep.Coverage,
+	}
+
+	for _, group := range rep.Plan {
+		for _, r := range group.Steps {
+			doc.Plan = append(doc.Plan, PlanStep{
+				Step:    len(doc.Plan) + 1,
+				Level:   group.Level,
+				Kind:    r.Kind,
+				Source:  r.Dependency.Source,
+				Current: r.Dependency.Version,
+				Latest:  r.wanted(),
+			})
+		}
+	}
+
+	for _, v := range rep.HostViolations {
+		doc.HostViolations = append(doc.HostViolations, Location{
+			Source:  v.Declaration.Source,
+			Version: v.Declaration.Version,
+			File:    v.Declaration.File,
+			Line:    v.Declaration.Line,
+		})
+	}
+
+	for _, group := range rep.Groups {
+		dir := Directory{Dir: group.Dir, Modules: []Result{}, Providers: []Result{}}
+		for _, r := range group.Results {
+			if r.Kind == kindProvider {
+				dir.Providers = append(dir.Providers, newResult(r))
+			} else {
+				dir.Modules = append(dir.Modules, newResult(r))
+			}
+		}
+		doc.Directories = append(doc.Directories, dir)
+	}
+
+	for _, r := range append(rep.Results, rep.Core...) {
+		res := newResult(r)
+		switch r.Kind {
+		case kindProvider:
+			doc.Providers = append(doc.Providers, res)
+		case kindCore:
+			doc.Core = append(doc.Core, res)
+		default:
+			doc.Modules = append(doc.Modules, res)
+		}
+	}
+
+	for _, res := range append(doc.Modules, doc.Providers...) {
+		doc.Summary.Total++
+		if res.Outdated {
+			doc.Summary.Outdated++
+		}
+		if res.Unpinned {
+			doc.Summary.Unpinned++
+		}
+		if res.Error != "" {
+			doc.Summary.Errored++
+		}
+	}
+	doc.Outdated = doc.Summary.Outdated > 0
+
+	return doc
+}
+
+// newResult converts a single lookup result into its JSON representation.
+func newResult(r lookupResult) Result {
+	res := Result{
+		Source:       r.Dependency.Source,
+		Lookup:       r.Dependency.Lookup,
+		Current:      r.Dependency.Version,
+		Declarations: []Location{},
+		Unpinned:     r.unpinned(),
+		NotFound:     r.NotFound,
+		Skipped:      r.Skipped,
+		Dynamic:      r.Dynamic,
+		NotChecked:   r.NotChecked,
+	}
+	if r.Err != nil {
+		res.Error = r.Err.Error()
+	} else {
+		res.Latest = r.Latest
+		res.Pinned = r.Pinned
+		res.Outdated = r.outdated()
+		res.Level = r.level()
+		res.Behind = r.behind()
+		res.Suggestion, res.Unpublished = r.unpublished()
+		res.BlockedBy = r.blockedBy()
+	}
+	for _, decl := range r.Dependency.Declarations {
+		res.Declarations = append(res.Declarations, Location{
+			Source:  decl.Source,
+			Version: decl.Version,
+			File:    decl.File,
+			Line:    decl.Line,
+		})
+	}
+	return res
+}
+
+// jsonLine is a single result in JSON Lines output, tagged with its kind.
+type jsonLine struct {
+	Type string `json:"type"`
+	Result
+}
+
+// renderJSONLine writes r as a single line of JSON.
+func renderJSONLine(w io.Writer, r lookupResult) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(jsonLine{Type: r.Kind, Result: newResult(r)})
+}
+
+// renderJSON writes the report as an indented JSON document.
+func renderJSON(w io.Writer, rep *report) error {
+	return writeJSON(w, newReport(rep))
+}
+
+// writeJSON writes v as indented JSON.
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(v)
+}
@@ -0,0 +1,35 @@
+package tfridge
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// logger writes diagnostics to stderr, separately from the report on stdout,
+// at the level chosen with --log-level.
+var logger = newLogger(io.Discard, slog.LevelWarn)
+
+// newLogger returns a logger writing text records without timestamps to w,
+// discarding records below level.
+func newLogger(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+// parseLogLevel parses one of debug, info, warn or error.
+func parseLogLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil || strings.ContainsAny(value, "+-") {
+		return 0, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", value)
+	}
+	return level, nil
+}
@@ -0,0 +1,140 @@
+package tfridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// mergedReport aggregates the JSON reports of several repositories.
+type mergedReport struct {
+	Repos     []string       `json:"repos"`
+	Modules   []mergedResult `json:"modules"`
+	Providers []mergedResult `json:"providers"`
+	Summary   mergeSummary   `json:"summary"`
+}
+
+// mergedResult is a result attributed to the repository it was reported for.
+type mergedResult struct {
+	Repo string `json:"repo"`
+	Result
+}
+
+// mergeSummary counts the results of a merged report.
+type mergeSummary struct {
+	Repos             int `json:"repos"`
+	Modules           int `json:"modules"`
+	OutdatedModules   int `json:"outdated_modules"`
+	Providers         int `json:"providers"`
+	OutdatedProviders int `json:"outdated_providers"`
+	Errors            int `json:"errors"`
+}
+
+// repoNames returns the repository each report file is attributed to, which
+// is the file name without its extension, or the path as given when several
+// files have the same name, as with a/report.json and b/report.json.
+func repoNames(paths []string) []string {
+	names := make([]string, len(paths))
+	count := make(map[string]int)
+	for i, path := range paths {
+		base := filepath.Base(path)
+		names[i] = strings.TrimSuffix(base, filepath.Ext(base))
+		count[names[i]]++
+	}
+	for i, path := range paths {
+		if count[names[i]] > 1 {
+			names[i] = filepath.ToSlash(path)
+		}
+	}
+	return names
+}
+
+// readReport reads a JSON report written by --format json.
+func readReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc Report
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &doc, nil
+}
+
+// mergeReports reads the JSON reports at paths and combines them into one.
+func mergeReports(paths []string) (*mergedReport, error) {
+	merged := &mergedReport{
+		Repos:     []string{},
+		Modules:   []mergedResult{},
+		Providers: []mergedResult{},
+	}
+
+	repos := repoNames(paths)
+	for i, path := range paths {
+		doc, err := readReport(path)
+		if err != nil {
+			return nil, err
+		}
+
+		repo := repos[i]
+		merged.Repos = append(merged.Repos, repo)
+		for _, res := range doc.Modules {
+			merged.Modules = append(merged.Modules, mergedResult{Repo: repo, Result: res})
+		}
+		for _, res := range doc.Providers {
+			merged.Providers = append(merged.Providers, mergedResult{Repo: repo, Result: res})
+		}
+	}
+
+	// Group the entries for the same source together, keeping the order of
+	// the reports within each source
+	for _, results := range [][]mergedResult{merged.Modules, merged.Providers} {
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Source < results[j].Source
+		})
+	}
+
+	merged.Summary = summarizeMerged(merged)
+	return merged, nil
+}
+
+// summarizeMerged recomputes the summary counts of a merged report.
+func summarizeMerged(merged *mergedReport) mergeSummary {
+	summary := mergeSummary{
+		Repos:     len(merged.Repos),
+		Modules:   len(merged.Modules),
+		Providers: len(merged.Providers),
+	}
+	for _, res := range merged.Modules {
+		if res.Outdated {
+			summary.OutdatedModules++
+		}
+		if res.Error != "" {
+			summary.Errors++
+		}
+	}
+	for _, res := range merged.Providers {
+		if res.Outdated {
+			summary.OutdatedProviders++
+		}
+		if res.Error != "" {
+			summary.Errors++
+		}
+	}
+	return summary
+}
+
+// runMerge merges the reports at paths and writes the result to w.
+func runMerge(w io.Writer, paths []string) error {
+	merged, err := mergeReports(paths)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, merged)
+}
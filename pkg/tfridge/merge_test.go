@@ -0,0 +1,96 @@
+package tfridge
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeReport writes doc as a JSON report at path below root, and returns
+// the full path.
+func writeReport(t *testing.T, root, path string, doc Report) string {
+	t.Helper()
+	path = filepath.Join(root, path)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := writeJSON(f, doc); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMergeReports(t *testing.T) {
+	root := t.TempDir()
+	network := writeReport(t, root, "network.json", Report{
+		Modules: []Result{
+			{Source: "terraform-aws-modules/vpc/aws", Current: "4.0.0", Latest: "5.2.0", Outdated: true},
+			{Source: "terraform-aws-modules/eks/aws", Current: "20.0.0", Latest: "20.0.0"},
+		},
+		Providers: []Result{
+			{Source: "hashicorp/aws", Current: "~> 5.0", Error: "failed to fetch latest version for provider, status code: 503"},
+		},
+	})
+	apps := writeReport(t, root, "apps.json", Report{
+		Modules: []Result{
+			{Source: "terraform-aws-modules/vpc/aws", Current: "5.2.0", Latest: "5.2.0"},
+		},
+		Providers: []Result{
+			{Source: "hashicorp/aws", Current: "5.0.0", Latest: "6.1.0", Outdated: true},
+			{Source: "hashicorp/random", Current: "3.6.0", Latest: "3.6.0"},
+		},
+	})
+
+	merged, err := mergeReports([]string{network, apps})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"network", "apps"}; !reflect.DeepEqual(merged.Repos, want) {
+		t.Errorf("got repos %v, want %v", merged.Repos, want)
+	}
+
+	var modules []string
+	for _, res := range merged.Modules {
+		modules = append(modules, res.Repo+" "+res.Source+" "+res.Current)
+	}
+	wantModules := []string{
+		"network terraform-aws-modules/eks/aws 20.0.0",
+		"network terraform-aws-modules/vpc/aws 4.0.0",
+		"apps terraform-aws-modules/vpc/aws 5.2.0",
+	}
+	if !reflect.DeepEqual(modules, wantModules) {
+		t.Errorf("got modules %q, want %q", modules, wantModules)
+	}
+
+	want := mergeSummary{Repos: 2, Modules: 3, OutdatedModules: 1, Providers: 3, OutdatedProviders: 1, Errors: 1}
+	if merged.Summary != want {
+		t.Errorf("got summary %+v, want %+v", merged.Summary, want)
+	}
+}
+
+func TestMergeReportsWithSameFileName(t *testing.T) {
+	root := t.TempDir()
+	a := writeReport(t, root, "a/report.json", Report{Modules: []Result{{Source: "terraform-aws-modules/vpc/aws", Current: "4.0.0"}}})
+	b := writeReport(t, root, "b/report.json", Report{Modules: []Result{{Source: "terraform-aws-modules/vpc/aws", Current: "5.2.0"}}})
+	other := writeReport(t, root, "c/other.json", Report{})
+
+	merged, err := mergeReports([]string{a, b, other})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{filepath.ToSlash(a), filepath.ToSlash(b), "other"}
+	if !reflect.DeepEqual(merged.Repos, want) {
+		t.Errorf("got repos %v, want %v", merged.Repos, want)
+	}
+	if merged.Modules[0].Repo == merged.Modules[1].Repo {
+		t.Errorf("the modules of a/report.json and b/report.json are attributed to the same repo %q", merged.Modules[0].Repo)
+	}
+}
@@ -0,0 +1,75 @@
+package tfridge
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// inventory returns a result for every module and provider found in the
+// scan, in order of source, without looking up any versions.
+func inventory(result *scanResult) []lookupResult {
+	var results []lookupResult
+	for _, dep := range sortedDependencies(result.moduleMap) {
+		results = append(results, lookupResult{Kind: kindModule, Dependency: dep})
+	}
+	for _, dep := range sortedDependencies(result.providerMap) {
+		results = append(results, lookupResult{Kind: kindProvider, Dependency: dep})
+	}
+	return results
+}
+
+// renderOffline renders the report of an --offline scan, which lists the
+// modules and providers found without looking up their versions.
+func renderOffline(w io.Writer, opts options, result *scanResult) error {
+	rep := &report{
+		RootPath: opts.rootPath,
+		Files:    result.files,
+		Results:  inventory(result),
+		Input:    opts.inputFile(),
+		Offline:  true,
+	}
+
+	switch opts.format {
+	case "json":
+		return renderJSON(w, rep)
+	case "jsonl":
+		for _, r := range rep.Results {
+			if err := renderJSONLine(w, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		renderInventory(w, rep)
+		return nil
+	}
+}
+
+// renderInventory prints every module and provider of an --offline scan with
+// its current version and each place it is declared.
+func renderInventory(w io.Writer, rep *report) {
+	for _, r := range rep.Results {
+		fmt.Fprintf(w, "%s source: %s\n", kindLabel(r.Kind), strings.Join(r.Dependency.declaredSources(), ", "))
+		if r.unpinned() {
+			fmt.Fprintf(w, "Current version: UNPINNED\n")
+		} else {
+			fmt.Fprintf(w, "Current version: %s\n", orDash(r.Dependency.Version))
+		}
+		fmt.Fprintf(w, "Declared at:\n")
+		for _, decl := range r.Dependency.Declarations {
+			fmt.Fprintf(w, "  %s:%d: %s\n", decl.File, decl.Line, orDash(decl.Version))
+		}
+		fmt.Fprintln(w)
+	}
+
+	var modules, providers int
+	for _, r := range rep.Results {
+		if r.Kind == kindProvider {
+			providers++
+		} else {
+			modules++
+		}
+	}
+	fmt.Fprintf(w, "Found %d modules and %d providers in %d .tf files, versions were not looked up\n", modules, providers, rep.Files)
+}
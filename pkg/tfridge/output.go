@@ -0,0 +1,518 @@
+package tfridge
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/fatih/color"
+)
+
+const (
+	kindModule   = "module"
+	kindProvider = "provider"
+	kindCore     = "core"
+)
+
+// lookupResult is the outcome of checking a single dependency against the registry.
+type lookupResult struct {
+	Kind       string
+	Dependency *dependency
+	Latest     string
+	Err        error
+
+	// NotFound is set when the lookup succeeded but found no published
+	// version, in which case Latest is empty.
+	NotFound bool
+
+	// Skipped is set when the lookup was not made because the source's host
+	// is not allowed.
+	Skipped bool
+
+	// Dynamic is set when the lookup was not made because the module source
+	// is interpolated and could not be resolved.
+	Dynamic bool
+
+	// NotChecked is set when the lookup was not made because the module is
+	// local or fetched from somewhere other than a registry or GitHub.
+	NotChecked bool
+
+	// Releases are the published versions the latest version was chosen
+	// from, sorted from newest to oldest. It is nil for the core version.
+	Releases []*semver.Version
+
+	// Published lists every published version, including the deprecated
+	// versions and prereleases left out of Releases.
+	Published []*semver.Version
+
+	// Pinned is the accepted version from the configuration, if any. Releases
+	// newer than it are not reported as outdated.
+	Pinned string
+}
+
+// newLookupResult returns the result of looking up dep, telling a dependency
+// with no published versions apart from a failed lookup.
+func newLookupResult(kind string, dep *dependency, latest string, err error) lookupResult {
+	r := lookupResult{Kind: kind, Dependency: dep, Latest: latest, Err: err}
+	if errors.Is(err, ErrNoVersions) {
+		r.Err = nil
+		r.NotFound = true
+	}
+	if errors.Is(err, ErrHostNotAllowed) {
+		r.Err = nil
+		r.Skipped = true
+	}
+	return r
+}
+
+// newReleasesResult returns the result of looking up dep from its releases
+// and published versions, sorted from newest to oldest. The first release is
+// the latest version.
+func newReleasesResult(kind string, dep *dependency, releases, published []*semver.Version, err error) lookupResult {
+	latest := ""
+	if len(releases) > 0 {
+		latest = releases[0].Original()
+	}
+	r := newLookupResult(kind, dep, latest, err)
+	r.Releases = releases
+	r.Published = published
+	return r
+}
+
+// wanted returns the version the dependency should be upgraded to: the pinned
+// version if there is one, or else the latest version.
+func (r lookupResult) wanted() string {
+	if r.Pinned != "" {
+		return r.Pinned
+	}
+	return r.Latest
+}
+
+// pinnedNote describes the releases a pin is ignoring, such as
+// "pinned (ignoring 5.x)", or returns an empty string if the latest version
+// is not newer than the pin.
+func (r lookupResult) pinnedNote() string {
+	if r.Pinned == "" || !isOutdated(r.Pinned, r.Latest) {
+		return ""
+	}
+	return fmt.Sprintf("pinned (ignoring %d.x)", semver.MustParse(r.Latest).Major())
+}
+
+// report is everything rendered at the end of a scan.
+type report struct {
+	RootPath        string
+	Files           int
+	Results         []lookupResult
+	Core            []lookupResult
+	UnusedProviders []providerRequirement
+	Targets         []targetCheck
+	ModuleProviders []moduleProviderCheck
+	Coverage        *Coverage
+	SharedFiles     []sharedFile
+	Plan            []planGroup
+	ShowPlan        bool
+	HostViolations  []hostViolation
+	Streamed        bool
+
+	// GroupByDir is set by --group-by dir, in which case the results are
+	// rendered from Groups.
+	GroupByDir bool
+	Groups     []dirGroup
+
+	// Input is the state or modules.json file read instead of scanning
+	// RootPath, if any.
+	Input string
+
+	// Offline is set by --offline, in which case Results have no versions
+	// looked up.
+	Offline bool
+}
+
+// outdated reports whether the registry has a newer version than the
+// declared one.
+func (r lookupResult) outdated() bool {
+	return r.Err == nil && isOutdated(r.Dependency.Version, r.wanted())
+}
+
+// outdatedDeclarations returns the declarations that are behind the wanted
+// version by an update of at least min, which may be empty for any update.
+func (r lookupResult) outdatedDeclarations(min string) []declaration {
+	if r.Err != nil {
+		return nil
+	}
+	var outdated []declaration
+	for _, decl := range r.Dependency.Declarations {
+		if level := updateLevel(decl.Version, r.wanted()); level != "" && atLeastLevel(level, min) {
+			outdated = append(outdated, decl)
+		}
+	}
+	return outdated
+}
+
+// unpublished reports whether the declared version is an exact version that
+// is not among the published versions, and returns the closest published
+// version as a suggestion.
+func (r lookupResult) unpublished() (string, bool) {
+	current := exactVersion(r.Dependency.Version)
+	if r.Err != nil || current == nil || len(r.Published) == 0 {
+		return "", false
+	}
+
+	var closest *semver.Version
+	for _, v := range r.Published {
+		if v.Equal(current) {
+			return "", false
+		}
+		if closest == nil || versionDistance(v, current).LessThan(versionDistance(closest, current)) {
+			closest = v
+		}
+	}
+	return closest.Original(), true
+}
+
+// unpublishedNote describes an unpublished declared version, such as
+// "4.0.0 not found in registry (did you mean 4.0.1?)", or returns an empty
+// string if it is published.
+func (r lookupResult) unpublishedNote() string {
+	suggestion, ok := r.unpublished()
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%s not found in registry (did you mean %s?)", exactVersion(r.Dependency.Version).Original(), suggestion)
+}
+
+// behind returns the number of releases newer than the declared version, up
+// to and including the wanted version. It returns 0 if either version cannot
+// be compared.
+func (r lookupResult) behind() int {
+	current := lowerBound(r.Dependency.Version)
+	wanted, err := semver.NewVersion(r.wanted())
+	if r.Err != nil || current == nil || err != nil {
+		return 0
+	}
+
+	behind := 0
+	for _, v := range r.Releases {
+		if v.GreaterThan(current) && !v.GreaterThan(wanted) {
+			behind++
+		}
+	}
+	return behind
+}
+
+// behindNote describes how far behind the declared version is, such as
+// "7 releases behind", or returns an empty string if it is not behind.
+func (r lookupResult) behindNote() string {
+	switch n := r.behind(); n {
+	case 0:
+		return ""
+	case 1:
+		return "1 release behind"
+	default:
+		return fmt.Sprintf("%d releases behind", n)
+	}
+}
+
+// level classifies the update to the wanted version as major, minor or patch,
+// or returns an empty string if the lookup failed or there is no update.
+func (r lookupResult) level() string {
+	if r.Err != nil {
+		return ""
+	}
+	return updateLevel(r.Dependency.Version, r.wanted())
+}
+
+// unpinned reports whether no declaration of a provider, registry module or
+// GitHub module constrains its version, so that it always tracks the latest.
+func (r lookupResult) unpinned() bool {
+	if r.Dependency.Version != "" {
+		return false
+	}
+	if r.Kind == kindProvider {
+		return true
+	}
+	if _, _, ok := githubRepo(r.Dependency.Source); ok {
+		return true
+	}
+	return r.Kind == kindModule && sourceKind(r.Dependency.Source) == sourceRegistry
+}
+
+// blockedBy returns the part of the declared constraint that rules out the
+// latest version, or an empty string if the latest version is allowed.
+func (r lookupResult) blockedBy() string {
+	if r.Err != nil || r.Latest == "" {
+		return ""
+	}
+	return upperBound(r.Dependency.Version, r.Latest)
+}
+
+// filterLevel drops the results whose update is below min, keeping failed
+// lookups so that they are still reported as errors.
+func filterLevel(results []lookupResult, min string) []lookupResult {
+	if min == "" {
+		return results
+	}
+
+	var filtered []lookupResult
+	for _, r := range results {
+		if r.Err != nil || atLeastLevel(r.level(), min) {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+// notCheckedNote describes why a module was not looked up.
+func (r lookupResult) notCheckedNote() string {
+	if sourceKind(r.Dependency.lookupSource()) == sourceLocal {
+		return "not checked (local module)"
+	}
+	return "not checked (not in a registry)"
+}
+
+// errorMessage describes why the lookup failed.
+func (r lookupResult) errorMessage() string {
+	sources := strings.Join(r.Dependency.declaredSources(), ", ")
+	switch r.Kind {
+	case kindProvider:
+		return fmt.Sprintf("Error fetching latest version for provider %s: %s", sources, r.Err)
+	case kindCore:
+		return fmt.Sprintf("Error fetching latest Terraform version: %s", r.Err)
+	default:
+		return fmt.Sprintf("Error fetching latest version for %s: %s", sources, r.Err)
+	}
+}
+
+// failedLookups returns the module, provider and core results whose lookup
+// failed.
+func (rep *report) failedLookups() []lookupResult {
+	var failed []lookupResult
+	for _, r := range append(rep.Results, rep.Core...) {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// kindLabel returns the capitalized name of a result kind for display.
+func kindLabel(kind string) string {
+	switch kind {
+	case kindProvider:
+		return "Provider"
+	case kindCore:
+		return "Terraform"
+	default:
+		return "Module"
+	}
+}
+
+// renderResult writes a single lookup result. Failed lookups are left for
+// the error section at the end of the report.
+func renderResult(w io.Writer, r lookupResult) {
+	if r.Err != nil {
+		return
+	}
+
+	sources := strings.Join(r.Dependency.declaredSources(), ", ")
+
+	fmt.Fprintf(w, "%s source: %s\n", kindLabel(r.Kind), sources)
+	if r.Dependency.Lookup != "" {
+		fmt.Fprintf(w, "Looked up as: %s\n", r.Dependency.Lookup)
+	}
+	if r.Skipped {
+		fmt.Fprintf(w, "Current version: %s\n", r.Dependency.Version)
+		fmt.Fprintf(w, "Latest version: skipped (host not allowed)\n\n")
+		return
+	}
+	if r.Dynamic {
+		fmt.Fprintf(w, "Current version: %s\n", r.Dependency.Version)
+		fmt.Fprintf(w, "Latest version: dynamic source (not resolvable)\n\n")
+		return
+	}
+	if r.NotChecked {
+		fmt.Fprintf(w, "Current version: %s\n", r.Dependency.Version)
+		fmt.Fprintf(w, "Latest version: %s\n\n", r.notCheckedNote())
+		return
+	}
+	if r.unpinned() && !r.NotFound {
+		fmt.Fprintf(w, "Current version: UNPINNED — latest is %s\n\n", r.Latest)
+		return
+	}
+	fmt.Fprintf(w, "Current version: %s\n", r.Dependency.Version)
+	if note := r.unpublishedNote(); note != "" {
+		fmt.Fprintf(w, "Current version %s\n", note)
+	}
+	if r.NotFound {
+		fmt.Fprintf(w, "Latest version: %s not found in registry\n\n", strings.ToLower(kindLabel(r.Kind)))
+	} else {
+		line := fmt.Sprintf("Latest version: %s", r.Latest)
+		if note := r.behindNote(); note != "" {
+			line += fmt.Sprintf(" (%s)", note)
+		}
+		if note := r.pinnedNote(); note != "" {
+			line += ", " + note
+		}
+		if c := latestColor(r); c != nil {
+			c.Fprintln(w, line)
+		} else {
+			fmt.Fprintln(w, line)
+		}
+		if level := r.level(); level != "" {
+			fmt.Fprintf(w, "Update: %s\n", level)
+		}
+		if bound := r.blockedBy(); bound != "" {
+			fmt.Fprintf(w, "Blocked by upper bound %s\n", bound)
+		}
+		fmt.Fprintf(w, "\n")
+	}
+}
+
+// latestColor returns the color of the "Latest version" line of r: green when
+// the declared version is current, yellow for a patch or minor update and red
+// for a major one. It returns nil for versions that cannot be compared.
+func latestColor(r lookupResult) *color.Color {
+	switch {
+	case r.level() == levelMajor:
+		return color.New(color.FgRed)
+	case r.level() != "":
+		return color.New(color.FgYellow)
+	case lowerBound(r.Dependency.Version) != nil:
+		return color.New(color.FgGreen)
+	default:
+		return nil
+	}
+}
+
+// renderText prints every result as a human readable block of lines, followed
+// by any cleanup suggestions.
+func renderText(w io.Writer, rep *report) {
+	switch {
+	case rep.GroupByDir:
+		renderGroups(w, rep.Groups, renderResults)
+	case !rep.Streamed:
+		renderResults(w, rep.Results)
+	}
+	renderSections(w, rep)
+}
+
+// renderResults writes every result as a block of lines.
+func renderResults(w io.Writer, results []lookupResult) {
+	for _, r := range results {
+		renderResult(w, r)
+	}
+}
+
+// renderSections prints the parts of a text report that follow the module
+// and provider results: the core version, the optional checks, cleanup
+// suggestions and failed lookups.
+func renderSections(w io.Writer, rep *report) {
+	for _, r := range rep.Core {
+		if r.Err != nil {
+			continue
+		}
+
+		fmt.Fprintf(w, "Terraform required version: %s\n", r.Dependency.Version)
+		if !r.NotFound {
+			fmt.Fprintf(w, "Latest version: %s\n", r.Latest)
+		}
+		fmt.Fprintf(w, "%s\n\n", coreStatus(r))
+	}
+
+	if len(rep.Targets) > 0 {
+		fmt.Fprintf(w, "Target versions:\n")
+		for _, tc := range rep.Targets {
+			fmt.Fprintf(w, "  %s:%d: %s\n", tc.Declaration.File, tc.Declaration.Line, tc.message())
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(rep.HostViolations) > 0 {
+		fmt.Fprintf(w, "Host policy violations:\n")
+		for _, v := range rep.HostViolations {
+			fmt.Fprintf(w, "  %s:%d: %s\n", v.Declaration.File, v.Declaration.Line, v.message())
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(rep.ModuleProviders) > 0 {
+		fmt.Fprintf(w, "Module provider references:\n")
+		for _, check := range rep.ModuleProviders {
+			fmt.Fprintf(w, "  %s:%d: %s\n", check.Ref.File, check.Ref.Line, check.message())
+		}
+		fmt.Fprintln(w)
+	}
+
+	if rep.ShowPlan {
+		renderPlan(w, rep.Plan)
+	}
+
+	if rep.Coverage != nil {
+		renderCoverage(w, rep.Coverage)
+	}
+
+	if len(rep.SharedFiles) > 0 {
+		fmt.Fprintf(w, "Shared configuration files:\n")
+		for _, shared := range rep.SharedFiles {
+			fmt.Fprintf(w, "  %s is included by %s\n", shared.File, strings.Join(shared.Dirs, ", "))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(rep.UnusedProviders) > 0 {
+		fmt.Fprintf(w, "Unused provider requirements:\n")
+		for _, req := range rep.UnusedProviders {
+			fmt.Fprintf(w, "  %s:%d: %s\n", req.File, req.Line, unusedProviderMessage(req))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if failed := rep.failedLookups(); len(failed) > 0 {
+		fmt.Fprintf(w, "Errors:\n")
+		for _, r := range failed {
+			fmt.Fprintf(w, "  %s\n", r.errorMessage())
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// renderSummary prints a one-line count of the files scanned and the modules
+// and providers found, and how many of them are outdated.
+func renderSummary(w io.Writer, rep *report) {
+	var modules, outdatedModules, providers, outdatedProviders, unpinned int
+	for _, r := range rep.Results {
+		if r.unpinned() {
+			unpinned++
+		}
+		switch r.Kind {
+		case kindModule:
+			modules++
+			if r.outdated() {
+				outdatedModules++
+			}
+		case kindProvider:
+			providers++
+			if r.outdated() {
+				outdatedProviders++
+			}
+		}
+	}
+
+	if rep.Input != "" {
+		fmt.Fprintf(w, "Read %s: %d modules (%d outdated), %d providers (%d outdated)",
+			filepath.Base(rep.Input), modules, outdatedModules, providers, outdatedProviders)
+	} else {
+		fmt.Fprintf(w, "Scanned %d .tf files: %d modules (%d outdated), %d providers (%d outdated)",
+			rep.Files, modules, outdatedModules, providers, outdatedProviders)
+	}
+	if unpinned > 0 {
+		fmt.Fprintf(w, ", %d unpinned", unpinned)
+	}
+	if failed := len(rep.failedLookups()); failed > 0 {
+		fmt.Fprintf(w, ", %d lookups failed", failed)
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,67 @@
+package tfridge
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// planGroup is a set of upgrades of the same level in a remediation plan.
+type planGroup struct {
+	Level string
+	Title string
+	Steps []lookupResult
+}
+
+// planGroups lists the groups of a remediation plan in the order they should
+// be worked through: low-risk upgrades first, major upgrades last.
+var planGroups = []planGroup{
+	{Level: levelPatch, Title: "Patch upgrades (safe to apply first)"},
+	{Level: levelMinor, Title: "Minor upgrades"},
+	{Level: levelMajor, Title: "Major upgrades (review for breaking changes)"},
+}
+
+// buildPlan groups every outdated module and provider by update level.
+// Within a group, modules come before providers and each is sorted by source.
+func buildPlan(results []lookupResult) []planGroup {
+	var plan []planGroup
+	for _, group := range planGroups {
+		for _, r := range results {
+			if r.Err == nil && updateLevel(r.Dependency.Version, r.wanted()) == group.Level {
+				group.Steps = append(group.Steps, r)
+			}
+		}
+		if len(group.Steps) == 0 {
+			continue
+		}
+
+		sort.SliceStable(group.Steps, func(i, j int) bool {
+			a, b := group.Steps[i], group.Steps[j]
+			if a.Kind != b.Kind {
+				return a.Kind == kindModule
+			}
+			return a.Dependency.Source < b.Dependency.Source
+		})
+		plan = append(plan, group)
+	}
+	return plan
+}
+
+// renderPlan prints the remediation plan as a numbered sequence of upgrades.
+func renderPlan(w io.Writer, plan []planGroup) {
+	fmt.Fprintf(w, "Remediation plan:\n")
+	if len(plan) == 0 {
+		fmt.Fprintf(w, "  Nothing to upgrade\n\n")
+		return
+	}
+
+	step := 0
+	for _, group := range plan {
+		fmt.Fprintf(w, "  %s:\n", group.Title)
+		for _, r := range group.Steps {
+			step++
+			fmt.Fprintf(w, "    %d. %s %s: %s -> %s\n", step, kindLabel(r.Kind), r.Dependency.Source, r.Dependency.Version, r.wanted())
+		}
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,76 @@
+package tfridge
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// lookupOf returns the lookup result of a dependency of kind declared at
+// version whose latest version is latest.
+func lookupOf(kind, source, version, latest string) lookupResult {
+	return lookupResult{Kind: kind, Dependency: &dependency{Source: source, Version: version}, Latest: latest}
+}
+
+func TestBuildPlan(t *testing.T) {
+	failed := lookupOf(kindModule, "acme/broken/aws", "1.0.0", "")
+	failed.Err = errors.New("failed to fetch latest version for module, status code: 503")
+	pinned := lookupOf(kindProvider, "hashicorp/kubernetes", "2.20.0", "3.0.0")
+	pinned.Pinned = "2.20.0"
+
+	results := []lookupResult{
+		lookupOf(kindProvider, "hashicorp/aws", "5.0.0", "6.1.0"),
+		lookupOf(kindProvider, "hashicorp/random", "3.6.0", "3.6.2"),
+		lookupOf(kindModule, "terraform-aws-modules/vpc/aws", "4.0.0", "5.2.0"),
+		lookupOf(kindModule, "terraform-aws-modules/eks/aws", "20.1.0", "20.8.0"),
+		lookupOf(kindProvider, "hashicorp/google", "5.1.0", "5.30.0"),
+		lookupOf(kindModule, "terraform-aws-modules/s3-bucket/aws", "4.1.0", "4.1.2"),
+		lookupOf(kindModule, "terraform-aws-modules/iam/aws", "5.30.0", "5.30.0"),
+		lookupOf(kindModule, "acme/empty/aws", "1.0.0", ""),
+		failed,
+		pinned,
+	}
+
+	var got [][]string
+	for _, group := range buildPlan(results) {
+		steps := []string{group.Level}
+		for _, r := range group.Steps {
+			steps = append(steps, r.Kind+" "+r.Dependency.Source)
+		}
+		got = append(got, steps)
+	}
+	want := [][]string{
+		{levelPatch, "module terraform-aws-modules/s3-bucket/aws", "provider hashicorp/random"},
+		{levelMinor, "module terraform-aws-modules/eks/aws", "provider hashicorp/google"},
+		{levelMajor, "module terraform-aws-modules/vpc/aws", "provider hashicorp/aws"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got plan %q, want %q", got, want)
+	}
+}
+
+func TestRenderPlan(t *testing.T) {
+	var out bytes.Buffer
+	renderPlan(&out, buildPlan([]lookupResult{
+		lookupOf(kindProvider, "hashicorp/aws", "5.0.0", "6.1.0"),
+		lookupOf(kindModule, "terraform-aws-modules/vpc/aws", "5.1.0", "5.1.2"),
+	}))
+
+	want := `Remediation plan:
+  Patch upgrades (safe to apply first):
+    1. Module terraform-aws-modules/vpc/aws: 5.1.0 -> 5.1.2
+  Major upgrades (review for breaking changes):
+    2. Provider hashicorp/aws: 5.0.0 -> 6.1.0
+`
+	if !strings.HasPrefix(out.String(), want) {
+		t.Errorf("got plan:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	renderPlan(&out, buildPlan(nil))
+	if !strings.Contains(out.String(), "Nothing to upgrade") {
+		t.Errorf("got plan %q without upgrades, want it to say there is nothing to upgrade", out.String())
+	}
+}
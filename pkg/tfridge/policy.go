@@ -0,0 +1,48 @@
+package tfridge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// hostViolation is a module declaration whose source is fetched from a host
+// that is not on the approved list.
+type hostViolation struct {
+	Declaration declaration
+	Host        string
+}
+
+// message describes the violation.
+func (v hostViolation) message() string {
+	return fmt.Sprintf("Module %s is fetched from %s, which is not an approved host", v.Declaration.Source, v.Host)
+}
+
+// checkApprovedHosts returns every module declaration whose source resolves to
+// a host that is not in approved, with registry addresses without a host
+// resolving to registryHost. Local and dynamic sources are not checked.
+func checkApprovedHosts(result *scanResult, approved []string, registryHost string) []hostViolation {
+	allowed := make(map[string]bool)
+	for _, host := range approved {
+		allowed[strings.ToLower(strings.TrimSpace(host))] = true
+	}
+
+	var violations []hostViolation
+	for _, dep := range sortedDependencies(result.moduleMap) {
+		for _, decl := range dep.Declarations {
+			host := moduleHost(decl.Source, registryHost)
+			if host != "" && !allowed[host] {
+				violations = append(violations, hostViolation{Declaration: decl, Host: host})
+			}
+		}
+	}
+
+	sort.Slice(violations, func(i, j int) bool {
+		a, b := violations[i].Declaration, violations[j].Declaration
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return violations
+}
@@ -0,0 +1,85 @@
+package tfridge
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckApprovedHosts(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"main.tf": `
+module "approved" {
+  source  = "tf.example.com/network/vpc/aws"
+  version = "1.0.0"
+}
+
+module "public" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.0.0"
+}
+
+module "github" {
+  source = "git::https://github.com/org/network.git?ref=v1.0.0"
+}
+
+module "local" {
+  source = "./modules/local"
+}
+
+module "dynamic" {
+  source = "${var.registry}/vpc/aws"
+}
+`,
+	})
+	result, err := scanDirectory(root, pathFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, v := range checkApprovedHosts(result, []string{" TF.example.com "}, defaultRegistryHost) {
+		got = append(got, v.Host+" "+v.Declaration.Source)
+		if v.Declaration.File != filepath.Join(root, "main.tf") {
+			t.Errorf("%s is reported in %s, want main.tf", v.Declaration.Source, v.Declaration.File)
+		}
+	}
+	want := []string{
+		"registry.terraform.io terraform-aws-modules/vpc/aws",
+		"github.com git::https://github.com/org/network.git?ref=v1.0.0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got violations %q, want %q", got, want)
+	}
+}
+
+func TestHostsResolveToSelectedRegistry(t *testing.T) {
+	registry := newFakeRegistry(t, map[string]string{
+		"/v1/modules/terraform-aws-modules/vpc/aws/versions": `{"modules":[{"versions":[{"version":"5.2.0"}]}]}`,
+		"/v1/providers/hashicorp/aws/versions":               `{"versions":[{"version":"5.0.0"}]}`,
+	})
+	client := registry.client()
+	client.BaseURL = "https://registry.opentofu.org"
+	client.ModulesURL = registry.URL + "/v1/modules"
+	client.ProvidersURL = registry.URL + "/v1/providers"
+	client.AllowedHosts = []string{"registry.opentofu.org"}
+
+	if _, err := client.LatestModuleVersion(context.Background(), "terraform-aws-modules/vpc/aws"); err != nil {
+		t.Errorf("module lookup on the allowed OpenTofu registry: %v", err)
+	}
+	if _, err := client.LatestProviderVersion(context.Background(), "hashicorp/aws"); err != nil {
+		t.Errorf("provider lookup on the allowed OpenTofu registry: %v", err)
+	}
+	if _, err := client.LatestProviderVersion(context.Background(), "registry.terraform.io/hashicorp/aws"); !errors.Is(err, ErrHostNotAllowed) {
+		t.Errorf("got %v for a provider on registry.terraform.io, want ErrHostNotAllowed", err)
+	}
+
+	if got := moduleHost("terraform-aws-modules/vpc/aws", client.registryHost()); got != "registry.opentofu.org" {
+		t.Errorf("got module host %s, want registry.opentofu.org", got)
+	}
+	if got := providerHost("hashicorp/aws", client.registryHost()); got != "registry.opentofu.org" {
+		t.Errorf("got provider host %s, want registry.opentofu.org", got)
+	}
+}
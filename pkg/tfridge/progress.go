@@ -0,0 +1,58 @@
+package tfridge
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// progress prints a "Checking n/total..." line that is rewritten in place as
+// registry lookups complete. A disabled progress prints nothing.
+type progress struct {
+	mu      sync.Mutex
+	w       io.Writer
+	total   int
+	done    int
+	enabled bool
+}
+
+// newProgress returns a progress indicator for total lookups. It is disabled
+// when quiet is set or when stdout or stderr is not a terminal.
+func newProgress(total int, quiet bool) *progress {
+	return &progress{
+		w:       os.Stderr,
+		total:   total,
+		enabled: !quiet && isTerminal(os.Stdout) && isTerminal(os.Stderr),
+	}
+}
+
+// increment records a completed lookup and redraws the indicator.
+func (p *progress) increment() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done++
+	if p.enabled {
+		fmt.Fprintf(p.w, "\rChecking %d/%d...", p.done, p.total)
+	}
+}
+
+// finish clears the indicator so it does not mix with the report.
+func (p *progress) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.enabled && p.done > 0 {
+		fmt.Fprint(p.w, "\r\033[K")
+	}
+}
+
+// isTerminal reports whether f is attached to a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
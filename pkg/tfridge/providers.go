@@ -0,0 +1,191 @@
+package tfridge
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// providerRequirement is a single entry of a required_providers block.
+type providerRequirement struct {
+	Name    string
+	Source  string
+	Version string
+	File    string
+	Line    int
+}
+
+// address returns the provider source the requirement resolves to, falling
+// back to the local name when no source is given.
+func (r providerRequirement) address() string {
+	if r.Source != "" {
+		return r.Source
+	}
+	return r.Name
+}
+
+// addRequirement records a required_providers entry and adds it to the
+// providers that are checked against the registry.
+func addRequirement(result *scanResult, req providerRequirement) {
+	result.requirements = append(result.requirements, req)
+
+	if err := validateProviderSource(req.address()); err != nil {
+		result.issues = append(result.issues, parseIssue{File: req.File, Line: req.Line, Message: err.Error()})
+	}
+	addDeclaration(result.providerMap, normalizeProviderAddress(req.address()), declaration{Source: req.address(), File: req.File, Line: req.Line, Version: req.Version})
+}
+
+// canonicalProvider returns the address a provider is identified by whatever
+// its spelling: normalized, without the host of a public registry and in
+// namespace, or the default namespace if that is empty, when it names none.
+// "aws", "hashicorp/aws" and "registry.terraform.io/hashicorp/aws" are the
+// same provider.
+func canonicalProvider(address, namespace string) string {
+	address = publicRegistryAddress(normalizeProviderAddress(address))
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	if address != "" && !strings.Contains(address, "/") {
+		address = namespace + "/" + address
+	}
+	return address
+}
+
+// canonicalizeProviders merges the providers of the scan declared with
+// different spellings of the same address, as given by canonicalProvider, so
+// that each is looked up and reported once.
+func canonicalizeProviders(result *scanResult, namespace string) {
+	providers := make(map[string]*dependency)
+	for _, dep := range sortedDependencies(result.providerMap) {
+		address := canonicalProvider(dep.Source, namespace)
+		if !strings.Contains(dep.Source, "/") {
+			logger.Debug("assuming the default namespace", "provider", dep.Source, "source", address)
+		}
+		for _, decl := range dep.Declarations {
+			addDeclaration(providers, address, decl)
+		}
+	}
+	result.providerMap = providers
+}
+
+// addProviderBlocks adds the providers configured by provider blocks to the
+// providers that are checked, with the block's legacy version argument as the
+// constraint. A block is left out if every directory that includes it
+// declares the provider in required_providers, which is checked instead.
+func addProviderBlocks(result *scanResult) {
+	required := make(map[string]map[string]bool)
+	for _, req := range result.requirements {
+		for _, dir := range result.dirsOf(req.File) {
+			if required[dir] == nil {
+				required[dir] = make(map[string]bool)
+			}
+			required[dir][req.Name] = true
+		}
+	}
+
+	for _, block := range result.providerBlocks {
+		declared := true
+		for _, dir := range result.dirsOf(block.File) {
+			declared = declared && required[dir][block.Name]
+		}
+		if declared {
+			continue
+		}
+
+		if err := validateProviderSource(block.Name); err != nil {
+			result.issues = append(result.issues, parseIssue{File: block.File, Line: block.Line, Message: err.Error()})
+		}
+		addDeclaration(result.providerMap, normalizeProviderAddress(block.Name), declaration{Source: block.Name, File: block.File, Line: block.Line, Version: block.Version})
+	}
+}
+
+// markProviderUsed records that a resource, data source or provider
+// meta-argument in dir references the provider with the given local name.
+func markProviderUsed(result *scanResult, dir, name string) {
+	if result.usedProviders[dir] == nil {
+		result.usedProviders[dir] = make(map[string]bool)
+	}
+	result.usedProviders[dir][name] = true
+}
+
+// unusedProviders returns the required_providers entries whose local name is
+// not referenced by any resource or data source in the directories that
+// include them. Directories that call modules count as using every provider,
+// because child modules inherit their default provider configurations from
+// the caller.
+func unusedProviders(result *scanResult) []providerRequirement {
+	var unused []providerRequirement
+	for _, req := range result.requirements {
+		used := false
+		for _, dir := range result.dirsOf(req.File) {
+			if result.moduleCallDirs[dir] || result.usedProviders[dir][req.Name] {
+				used = true
+				break
+			}
+		}
+		if !used {
+			unused = append(unused, req)
+		}
+	}
+	return unused
+}
+
+// unusedProviderMessage describes an unused provider requirement.
+func unusedProviderMessage(req providerRequirement) string {
+	return fmt.Sprintf("Provider %s (%s) is required but not used by any resource or data source", req.Name, req.address())
+}
+
+// moduleProviderRef is a single entry of a module call's providers map, such
+// as "aws = aws.west", where Child is the provider name inside the module and
+// Parent the provider configuration passed from the caller.
+type moduleProviderRef struct {
+	Module string
+	File   string
+	Line   int
+	Child  string
+	Parent string
+}
+
+// parentName returns the local name of the caller's provider, without alias.
+func (r moduleProviderRef) parentName() string {
+	return strings.SplitN(r.Parent, ".", 2)[0]
+}
+
+// moduleProviderCheck is a module provider reference cross-referenced with the
+// required_providers of the calling directory.
+type moduleProviderCheck struct {
+	Ref      moduleProviderRef
+	Declared bool
+}
+
+// message describes the reference and whether its provider is declared.
+func (c moduleProviderCheck) message() string {
+	msg := fmt.Sprintf("module %q receives %s = %s", c.Ref.Module, c.Ref.Child, c.Ref.Parent)
+	if !c.Declared {
+		msg += fmt.Sprintf(" (provider %s is not declared in required_providers)", c.Ref.parentName())
+	}
+	return msg
+}
+
+// checkModuleProviderRefs reports, for every provider passed to a module call,
+// whether the caller declares it in required_providers.
+func checkModuleProviderRefs(result *scanResult) []moduleProviderCheck {
+	declared := make(map[string]map[string]bool)
+	for _, req := range result.requirements {
+		for _, dir := range result.dirsOf(req.File) {
+			if declared[dir] == nil {
+				declared[dir] = make(map[string]bool)
+			}
+			declared[dir][req.Name] = true
+		}
+	}
+
+	var checks []moduleProviderCheck
+	for _, ref := range result.moduleProviderRefs {
+		checks = append(checks, moduleProviderCheck{
+			Ref:      ref,
+			Declared: declared[filepath.Dir(ref.File)][ref.parentName()],
+		})
+	}
+	return checks
+}
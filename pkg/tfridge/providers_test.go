@@ -0,0 +1,474 @@
+package tfridge
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestUnusedProviders(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"versions.tf": `
+terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 5.0"
+    }
+    random = {
+      source  = "hashicorp/random"
+      version = "3.6.0"
+    }
+    google = {
+      source = "hashicorp/google"
+    }
+  }
+}
+`,
+		"main.tf": `
+resource "aws_instance" "web" {
+  ami = "ami-123"
+}
+
+data "aws_ami" "ubuntu" {
+  most_recent = true
+}
+
+resource "null_resource" "noop" {
+  provider = google
+}
+`,
+	})
+
+	result, err := scanDirectory(root, pathFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unused := unusedProviders(result)
+	if len(unused) != 1 {
+		t.Fatalf("got %d unused providers, want 1: %+v", len(unused), unused)
+	}
+	if unused[0].Name != "random" || unused[0].Source != "hashicorp/random" {
+		t.Errorf("got unused provider %s (%s), want random (hashicorp/random)", unused[0].Name, unused[0].Source)
+	}
+}
+
+func TestUnusedProvidersInModuleCallers(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"main.tf": `
+terraform {
+  required_providers {
+    aws = {
+      source = "hashicorp/aws"
+    }
+  }
+}
+
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.0.0"
+}
+`,
+	})
+
+	result, err := scanDirectory(root, pathFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The module inherits the caller's default aws configuration
+	if unused := unusedProviders(result); len(unused) != 0 {
+		t.Errorf("got unused providers %+v in a directory calling a module, want none", unused)
+	}
+}
+
+func TestModuleProvidersArgument(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"main.tf": `
+terraform {
+  required_providers {
+    aws = {
+      source = "hashicorp/aws"
+    }
+  }
+}
+
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.0.0"
+
+  providers = {
+    aws        = aws.west
+    aws.peer   = aws.east
+  }
+}
+
+module "dns" {
+  source    = "./modules/dns"
+  providers = { google = google.dns }
+}
+`,
+		"config.tf.json": `{
+  "module": {
+    "cdn": {
+      "source": "./modules/cdn",
+      "providers": {"aws": "aws.edge"}
+    }
+  }
+}`,
+	})
+
+	result, err := scanDirectory(root, pathFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, check := range checkModuleProviderRefs(result) {
+		got = append(got, check.message())
+	}
+	want := []string{
+		`module "cdn" receives aws = aws.edge`,
+		`module "vpc" receives aws = aws.west`,
+		`module "vpc" receives aws.peer = aws.east`,
+		`module "dns" receives google = google.dns (provider google is not declared in required_providers)`,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got module provider references %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("reference %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	if result.moduleProviderRefs[1].Line != 14 {
+		t.Errorf("got the providers argument on line %d, want 14", result.moduleProviderRefs[1].Line)
+	}
+	if len(result.issues) != 0 {
+		t.Errorf("got parse issues %v", result.issues)
+	}
+}
+
+func TestCentralVersionsFile(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"versions.tf": `
+terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 5.0"
+    }
+  }
+}
+`,
+		"stacks/network/main.tf": `
+resource "aws_vpc" "main" {
+  cidr_block = "10.0.0.0/16"
+}
+`,
+		"stacks/dns/main.tf": `
+resource "aws_route53_zone" "main" {
+  name = "example.com"
+}
+`,
+	})
+	for _, stack := range []string{"network", "dns"} {
+		if err := os.Symlink(filepath.Join("..", "..", "versions.tf"), filepath.Join(root, "stacks", stack, "versions.tf")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := scanDirectory(root, pathFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.files != 3 {
+		t.Errorf("scanned %d files, want the central versions.tf once and each main.tf", result.files)
+	}
+	aws := result.providerMap["hashicorp/aws"]
+	if aws == nil || len(aws.Declarations) != 1 || len(result.requirements) != 1 {
+		t.Fatalf("got providers %v and requirements %v, want hashicorp/aws declared once", result.providerMap, result.requirements)
+	}
+	if unused := unusedProviders(result); len(unused) != 0 {
+		t.Errorf("got unused providers %v, want aws used by the stacks including versions.tf", unused)
+	}
+
+	shared := result.sharedFiles()
+	if len(shared) == 1 {
+		sort.Strings(shared[0].Dirs)
+	}
+	wantDirs := []string{root, filepath.Join(root, "stacks", "dns"), filepath.Join(root, "stacks", "network")}
+	if len(shared) != 1 || shared[0].File != filepath.Join(root, "versions.tf") || !reflect.DeepEqual(shared[0].Dirs, wantDirs) {
+		t.Errorf("got shared files %+v, want versions.tf included by %v", shared, wantDirs)
+	}
+
+	var dirs []string
+	for _, group := range groupByDir(root, inventory(result), result, "") {
+		if len(group.Results) != 1 || group.Results[0].Dependency.Source != "hashicorp/aws" {
+			t.Errorf("directory %s has results %+v, want only hashicorp/aws", group.Dir, group.Results)
+		}
+		dirs = append(dirs, group.Dir)
+	}
+	if want := []string{".", "stacks/dns", "stacks/network"}; !reflect.DeepEqual(dirs, want) {
+		t.Errorf("got directories %v, want %v", dirs, want)
+	}
+}
+
+func TestProviderBlocksWithoutRequirement(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"main.tf": `
+terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 5.0"
+    }
+  }
+}
+
+provider "aws" {
+  region = "eu-west-1"
+}
+
+provider "google" {
+  version = "~> 3.0"
+  batching {
+    version = "9.9.9"
+  }
+}
+
+provider "null" {}
+`,
+		"main.tf.json": `{"provider": {"random": {"version": "~> 2.0"}}}`,
+		"legacy/main.tf": `
+provider "aws" { version = "~> 2.70" }
+`,
+	})
+
+	result, err := scanDirectory(root, pathFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type decl struct {
+		file, version string
+	}
+	got := make(map[string][]decl)
+	for address, dep := range result.providerMap {
+		for _, d := range dep.Declarations {
+			rel, _ := filepath.Rel(root, d.File)
+			got[address] = append(got[address], decl{filepath.ToSlash(rel), d.Version})
+		}
+	}
+	want := map[string][]decl{
+		"hashicorp/aws": {{"main.tf", "~> 5.0"}},
+		"aws":           {{"legacy/main.tf", "~> 2.70"}},
+		"google":        {{"main.tf", "~> 3.0"}},
+		"null":          {{"main.tf", ""}},
+		"random":        {{"main.tf.json", "~> 2.0"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got provider declarations %v, want %v", got, want)
+	}
+	if len(result.issues) != 0 {
+		t.Errorf("got issues %v, want none", result.issues)
+	}
+}
+
+func TestRequirementAttributesAtEntryDepth(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"main.tf": `
+terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 5.0"
+      # version = "~> 4.0"
+      meta = {
+        version = "9.9.9"
+      }
+    }
+    random = { source = 'hashicorp/random', version = "3.6.0" }
+    null = "~> 3.0"
+  }
+}
+`,
+	})
+	result, err := scanDirectory(root, pathFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]string)
+	for _, req := range result.requirements {
+		got[req.address()] = req.Version
+	}
+	want := map[string]string{
+		"hashicorp/aws":    "~> 5.0",
+		"hashicorp/random": "3.6.0",
+		"null":             "~> 3.0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got requirements %v, want %v", got, want)
+	}
+}
+
+func TestCanonicalizeProviders(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"a/main.tf": `
+terraform {
+  required_providers {
+    aws = "~> 4.0"
+  }
+}
+`,
+		"b/main.tf": `
+terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 5.0"
+    }
+    random = {
+      source = "Registry.Terraform.io/HashiCorp/random"
+    }
+  }
+}
+`,
+		"c/main.tf": `
+terraform {
+  required_providers {
+    aws = {
+      source  = "registry.terraform.io/hashicorp/aws"
+      version = "5.1.0"
+    }
+    widget = "1.0.0"
+  }
+}
+`,
+	})
+
+	tests := []struct {
+		namespace string
+		want      map[string]int
+	}{
+		{"", map[string]int{"hashicorp/aws": 3, "hashicorp/random": 1, "hashicorp/widget": 1}},
+		{"acme", map[string]int{"acme/aws": 1, "hashicorp/aws": 2, "hashicorp/random": 1, "acme/widget": 1}},
+	}
+	for _, tt := range tests {
+		result, err := scanDirectory(root, pathFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		canonicalizeProviders(result, tt.namespace)
+
+		got := make(map[string]int)
+		for address, dep := range result.providerMap {
+			got[address] = len(dep.Declarations)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("namespace %q: got declarations by provider %v, want %v", tt.namespace, got, tt.want)
+		}
+	}
+}
+
+func TestProviderSpellingsAreLookedUpOnce(t *testing.T) {
+	registry := newFakeRegistry(t, map[string]string{
+		"/v1/providers/hashicorp/aws/versions": `{"versions":[{"version":"5.0.0"},{"version":"5.2.0"}]}`,
+	})
+	registry.useAsDefault(t)
+	root := writeFiles(t, map[string]string{
+		"main.tf": `
+terraform {
+  required_providers {
+    aws = "~> 5.0"
+  }
+}
+`,
+		"legacy/main.tf": `
+terraform {
+  required_providers {
+    aws = {
+      source  = "registry.terraform.io/hashicorp/aws"
+      version = "5.0.0"
+    }
+  }
+}
+`,
+	})
+
+	output := filepath.Join(t.TempDir(), "report.json")
+	if err := runApp(t, "--format", "json", "-o", output, root); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := readReport(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Providers) != 1 || doc.Providers[0].Source != "hashicorp/aws" || len(doc.Providers[0].Declarations) != 2 {
+		t.Errorf("got providers %+v, want hashicorp/aws declared twice", doc.Providers)
+	}
+	var lookups int
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	for _, path := range registry.requests {
+		if strings.HasPrefix(path, "/v1/providers/") {
+			lookups++
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("made %d provider lookups, want one for both spellings", lookups)
+	}
+}
+
+func TestJSONDeclarationOrder(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"main.tf.json": `{
+  "terraform": {"required_providers": {
+    "null": {"source": "hashicorp/null"},
+    "aws": {"source": "hashicorp/aws"},
+    "random": {"source": "hashicorp/random"},
+    "google": {"source": "hashicorp/google"},
+    "tls": {"source": "hashicorp/tls"}
+  }},
+  "module": {
+    "vpc": {"source": "terraform-aws-modules/vpc/aws", "providers": {"aws": "aws.west", "null": "null", "tls": "tls.main"}},
+    "eks": {"source": "terraform-aws-modules/vpc/aws//modules/eks"},
+    "app": {"source": "terraform-aws-modules/vpc/aws?ref=x"}
+  }
+}`,
+	})
+
+	var first string
+	for i := 0; i < 20; i++ {
+		result, err := scanDirectory(root, pathFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var order []string
+		for _, req := range result.requirements {
+			order = append(order, req.Name)
+		}
+		for _, ref := range result.moduleProviderRefs {
+			order = append(order, ref.Child)
+		}
+		for _, dep := range sortedDependencies(result.moduleMap) {
+			order = append(order, dep.declaredSources()...)
+		}
+		got := strings.Join(order, " ")
+		if i == 0 {
+			first = got
+		} else if got != first {
+			t.Fatalf("scan %d declared %q, the first scan %q", i+1, got, first)
+		}
+	}
+	if want := "aws google null random tls aws null tls terraform-aws-modules/vpc/aws?ref=x terraform-aws-modules/vpc/aws//modules/eks terraform-aws-modules/vpc/aws"; first != want {
+		t.Errorf("got %q, want %q", first, want)
+	}
+}
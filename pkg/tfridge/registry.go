@@ -0,0 +1,667 @@
+package tfridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"golang.org/x/time/rate"
+)
+
+// defaultRegistryURL is the base URL of the public Terraform registry.
+const defaultRegistryURL = "https://registry.terraform.io"
+
+// defaultNamespace is the namespace Terraform assumes for providers whose
+// source does not name one.
+const defaultNamespace = "hashicorp"
+
+// registryPresets maps the names accepted by --registry to the base URL of
+// the public registry they refer to. Both implement the same module and
+// provider registry protocols.
+var registryPresets = map[string]string{
+	"terraform": defaultRegistryURL,
+	"opentofu":  "https://registry.opentofu.org",
+}
+
+// Service discovery document and the paths used when a registry does not
+// publish one.
+const (
+	discoveryPath        = "/.well-known/terraform.json"
+	defaultModulesPath   = "/v1/modules/"
+	defaultProvidersPath = "/v1/providers/"
+)
+
+// defaultRate is the default limit on requests per second, which keeps large
+// scans from being throttled by the public registry.
+const defaultRate = 10
+
+// The default retry policy waits defaultBackoffBase before the first retry and
+// doubles the wait before each of the following ones.
+const (
+	defaultRetries     = 3
+	defaultBackoffBase = 500 * time.Millisecond
+)
+
+// registryMeta is the pagination metadata included in registry list responses.
+type registryMeta struct {
+	Limit         int    `json:"limit"`
+	CurrentOffset int    `json:"current_offset"`
+	NextOffset    int    `json:"next_offset"`
+	NextURL       string `json:"next_url"`
+}
+
+// discoveryResponse is the service discovery document of a registry host.
+type discoveryResponse struct {
+	Modules   string `json:"modules.v1"`
+	Providers string `json:"providers.v1"`
+}
+
+// moduleVersionsResponse is returned by /v1/modules/:namespace/:name/:provider/versions.
+type moduleVersionsResponse struct {
+	Modules []moduleVersions `json:"modules"`
+	Meta    registryMeta     `json:"meta"`
+}
+
+// moduleVersions lists the published versions of a single module.
+type moduleVersions struct {
+	Source   string          `json:"source"`
+	Versions []ModuleVersion `json:"versions"`
+}
+
+// ModuleVersion is a single published version of a module.
+type ModuleVersion struct {
+	Version     string              `json:"version"`
+	Deprecation *VersionDeprecation `json:"deprecation"`
+}
+
+// VersionDeprecation is included with a published version that the registry
+// has marked as deprecated.
+type VersionDeprecation struct {
+	Reason string `json:"reason"`
+	Link   string `json:"link"`
+}
+
+// providerVersionsResponse is returned by /v1/providers/:namespace/:type/versions.
+type providerVersionsResponse struct {
+	ID       string            `json:"id"`
+	Versions []ProviderVersion `json:"versions"`
+	Warnings []string          `json:"warnings"`
+	Meta     registryMeta      `json:"meta"`
+}
+
+// ProviderVersion is a single published version of a provider, with the
+// plugin protocols and platforms it supports.
+type ProviderVersion struct {
+	Version     string              `json:"version"`
+	Protocols   []string            `json:"protocols"`
+	Platforms   []ProviderPlatform  `json:"platforms"`
+	Deprecation *VersionDeprecation `json:"deprecation"`
+}
+
+// ProviderPlatform is an operating system and architecture a provider
+// version is built for.
+type ProviderPlatform struct {
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+}
+
+// Client looks up module and provider versions in a Terraform registry, and
+// Terraform CLI versions in the HashiCorp releases API.
+type Client struct {
+	BaseURL     string
+	ReleasesURL string
+	HTTPClient  *http.Client
+
+	// ModulesURL and ProvidersURL are the base URLs of the module and
+	// provider registry APIs, as found by Discover. When empty, the standard
+	// paths below BaseURL are used.
+	ModulesURL   string
+	ProvidersURL string
+
+	// GitHubURL is the GitHub API used to look up the tags of modules hosted
+	// on GitHub. GitHubToken, if set, is sent to it to raise the rate limit.
+	GitHubURL   string
+	GitHubToken string
+
+	// IncludeDeprecated makes the latest version lookups consider versions
+	// the registry has marked as deprecated, which are skipped by default.
+	IncludeDeprecated bool
+
+	// Logger receives a debug record for every request and an info record
+	// for every retry. When nil, the default slog logger is used.
+	Logger *slog.Logger
+
+	// AllowedHosts, if not empty, restricts lookups to the modules and
+	// providers whose source resolves to one of these hosts, and registry
+	// lookups to a registry at BaseURL on one of them. Any other lookup fails
+	// with ErrHostNotAllowed without making a request.
+	AllowedHosts []string
+
+	// Limiter, if set, limits the rate of requests, including retries.
+	Limiter *rate.Limiter
+
+	// Backoff is the time to wait before each retry of a failed request, to
+	// which a random jitter of up to half the interval is added. The number
+	// of intervals is the number of retries. When nil, an exponential
+	// schedule of defaultRetries retries is used.
+	Backoff []time.Duration
+
+	// DefaultNamespace is the namespace of provider sources that do not name
+	// one, such as "random". When empty, it is defaultNamespace.
+	DefaultNamespace string
+
+	// ProviderProtocols, if set, makes the latest provider version lookups
+	// only consider versions that support one of these plugin protocol major
+	// versions, such as those of the Terraform release in use.
+	ProviderProtocols []int
+
+	// budget, if set, caps the number of requests made, including retries.
+	budget *requestBudget
+
+	// cache, if set, keeps the versions found by a lookup for later lookups
+	// of the same source, in the same scan or, with --watch, the next.
+	cache *releaseCache
+
+	// stats, if set, counts the requests made and the bytes downloaded.
+	stats *scanStats
+
+	// sleep, if set, is called instead of waiting on a timer before each
+	// retry, so that tests can record the waits.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewClient returns a Client for the registry at baseURL.
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL:     strings.TrimSuffix(baseURL, "/"),
+		ReleasesURL: defaultReleasesURL,
+		GitHubURL:   defaultGitHubURL,
+		HTTPClient:  http.DefaultClient,
+	}
+}
+
+// DefaultClient is the client the CLI uses to query the public registry.
+var DefaultClient = NewClient(defaultRegistryURL)
+
+// Discover reads the service discovery document of the registry at BaseURL
+// and sets ModulesURL and ProvidersURL from it. The standard paths are kept
+// for any service the document does not list.
+func (c *Client) Discover(ctx context.Context) error {
+	if err := c.checkHost(c.registryHost()); err != nil {
+		return err
+	}
+
+	var doc discoveryResponse
+	if err := c.getJSON(ctx, c.BaseURL+discoveryPath, &doc, "failed to discover registry services"); err != nil {
+		return err
+	}
+	if doc.Modules != "" {
+		c.ModulesURL = c.resolve(doc.Modules)
+	}
+	if doc.Providers != "" {
+		c.ProvidersURL = c.resolve(doc.Providers)
+	}
+	return nil
+}
+
+// resolve returns ref as an absolute URL, treating a path as relative to
+// BaseURL, and without a trailing slash.
+func (c *Client) resolve(ref string) string {
+	if !strings.Contains(ref, "://") {
+		ref = c.BaseURL + "/" + strings.TrimPrefix(ref, "/")
+	}
+	return strings.TrimSuffix(ref, "/")
+}
+
+// modulesURL returns the base URL of the module registry API.
+func (c *Client) modulesURL() string {
+	if c.ModulesURL != "" {
+		return c.ModulesURL
+	}
+	return c.resolve(defaultModulesPath)
+}
+
+// providersURL returns the base URL of the provider registry API.
+func (c *Client) providersURL() string {
+	if c.ProvidersURL != "" {
+		return c.ProvidersURL
+	}
+	return c.resolve(defaultProvidersPath)
+}
+
+// ModuleVersions returns every published version of a registry module,
+// following pagination links. Any subdirectory or query string in the source
+// is ignored, and the address is normalized as by normalizeModuleAddress. An
+// address may name the host of the registry queried or of a public registry.
+func (c *Client) ModuleVersions(ctx context.Context, moduleSource string) ([]ModuleVersion, error) {
+	moduleSource = normalizeModuleAddress(moduleAddress(moduleSource))
+	if err := c.checkHost(moduleHost(moduleSource, c.registryHost()), c.registryHost()); err != nil {
+		return nil, err
+	}
+	address, err := c.stripHost(moduleSource, 4)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []ModuleVersion
+
+	url := fmt.Sprintf("%s/%s/versions", c.modulesURL(), address)
+	for url != "" {
+		var page moduleVersionsResponse
+		if err := c.getJSON(ctx, url, &page, "failed to fetch latest version"); err != nil {
+			return nil, err
+		}
+		for _, module := range page.Modules {
+			versions = append(versions, module.Versions...)
+		}
+		url = c.nextURL(url, page.Meta)
+	}
+
+	return versions, nil
+}
+
+// LatestModuleVersion returns the newest version of a registry module that is
+// not deprecated, unless IncludeDeprecated is set.
+func (c *Client) LatestModuleVersion(ctx context.Context, moduleSource string) (string, error) {
+	releases, _, err := c.moduleReleases(ctx, moduleSource)
+	if err != nil {
+		return "", err
+	}
+	return releases[0].Original(), nil
+}
+
+// moduleReleases returns the versions of a registry module that
+// LatestModuleVersion considers, and every published version including
+// deprecated ones, both sorted from newest to oldest.
+func (c *Client) moduleReleases(ctx context.Context, moduleSource string) (releases, published []*semver.Version, err error) {
+	versions, err := c.ModuleVersions(ctx, moduleSource)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var names, considered []string
+	for _, v := range versions {
+		names = append(names, v.Version)
+		if v.Deprecation == nil || c.IncludeDeprecated {
+			considered = append(considered, v.Version)
+		}
+	}
+	published, _ = sortVersions(names)
+	releases, err = sortVersions(considered)
+	return releases, published, err
+}
+
+// ProviderVersions returns every published version of a registry provider,
+// following pagination links. Sources without a namespace are assumed to be
+// in DefaultNamespace, and a source may name the host of the registry queried
+// or of a public registry.
+func (c *Client) ProviderVersions(ctx context.Context, providerSource string) ([]ProviderVersion, error) {
+	providerSource = normalizeProviderAddress(providerSource)
+	if err := c.checkHost(providerHost(providerSource, c.registryHost()), c.registryHost()); err != nil {
+		return nil, err
+	}
+	providerSource, err := c.stripHost(providerSource, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the provider name already contains a namespace
+	parts := strings.Split(providerSource, "/")
+	if len(parts) == 2 {
+		// This is already in the correct format (namespace/provider)
+	} else if len(parts) == 1 {
+		// Assume the provider is in the default namespace
+		providerSource = c.defaultNamespace() + "/" + providerSource
+		c.logger().Debug("assuming the default namespace", "provider", parts[0], "source", providerSource)
+	} else {
+		return nil, fmt.Errorf("provider format is incorrect: %s", providerSource)
+	}
+
+	var versions []ProviderVersion
+
+	// Construct the URL for the provider registry
+	url := fmt.Sprintf("%s/%s/versions", c.providersURL(), providerSource)
+	for url != "" {
+		var page providerVersionsResponse
+		if err := c.getJSON(ctx, url, &page, "failed to fetch latest version for provider"); err != nil {
+			return nil, err
+		}
+		versions = append(versions, page.Versions...)
+		url = c.nextURL(url, page.Meta)
+	}
+
+	return versions, nil
+}
+
+// stripHost returns address without its host if it has parts elements, the
+// first of which is the host. The public registries publish the same modules
+// and providers, so the host of either may be queried on the other; any other
+// host must be that of the registry queried.
+func (c *Client) stripHost(address string, parts int) (string, error) {
+	host, rest, found := strings.Cut(address, "/")
+	if !found || strings.Count(address, "/") != parts-1 {
+		return address, nil
+	}
+	if strings.EqualFold(host, c.registryHost()) || publicRegistryAddress(address) != address {
+		return rest, nil
+	}
+	return "", fmt.Errorf("%s is published on %s, not on the registry queried (%s)", address, host, c.registryHost())
+}
+
+// defaultNamespace returns the namespace of provider sources without one.
+func (c *Client) defaultNamespace() string {
+	if c.DefaultNamespace != "" {
+		return c.DefaultNamespace
+	}
+	return defaultNamespace
+}
+
+// LatestProviderVersion returns the newest version of a registry provider
+// that is not deprecated, unless IncludeDeprecated is set, and that supports
+// one of ProviderProtocols, if set.
+func (c *Client) LatestProviderVersion(ctx context.Context, providerSource string) (string, error) {
+	releases, _, err := c.providerReleases(ctx, providerSource)
+	if err != nil {
+		return "", err
+	}
+	return releases[0].Original(), nil
+}
+
+// providerReleases returns the versions of a registry provider that
+// LatestProviderVersion considers, and every published version including
+// deprecated ones, both sorted from newest to oldest.
+func (c *Client) providerReleases(ctx context.Context, providerSource string) (releases, published []*semver.Version, err error) {
+	versions, err := c.ProviderVersions(ctx, providerSource)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var names, considered []string
+	for _, v := range versions {
+		names = append(names, v.Version)
+		if (v.Deprecation == nil || c.IncludeDeprecated) && c.protocolSupported(v) {
+			considered = append(considered, v.Version)
+		}
+	}
+	published, _ = sortVersions(names)
+	releases, err = sortVersions(considered)
+	return releases, published, err
+}
+
+// protocolSupported reports whether a provider version supports one of
+// ProviderProtocols. Versions that do not list their protocols are assumed to
+// be supported.
+func (c *Client) protocolSupported(v ProviderVersion) bool {
+	if len(c.ProviderProtocols) == 0 || len(v.Protocols) == 0 {
+		return true
+	}
+	for _, protocol := range v.Protocols {
+		major, _, _ := strings.Cut(protocol, ".")
+		for _, supported := range c.ProviderProtocols {
+			if major == strconv.Itoa(supported) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// logger returns the logger diagnostics are written to.
+func (c *Client) logger() *slog.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return slog.Default()
+}
+
+// getJSON fetches url and decodes the JSON response into v. A response
+// other than 200 OK is reported as an error prefixed with what.
+func (c *Client) getJSON(ctx context.Context, url string, v interface{}, what string) error {
+	resp, err := c.get(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s, status code: %d", what, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// get performs a GET request, retrying network errors, 429 and 5xx responses
+// after each interval of the backoff schedule, or after the delay given by a
+// Retry-After header. It gives up as soon as ctx is cancelled, including while
+// waiting to retry.
+func (c *Client) get(ctx context.Context, url string) (*http.Response, error) {
+	schedule := c.Backoff
+	if schedule == nil {
+		schedule = exponentialBackoff(defaultRetries, defaultBackoffBase)
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		// The token is only ever sent to the GitHub API
+		if c.GitHubToken != "" && strings.HasPrefix(url, c.GitHubURL+"/") {
+			req.Header.Set("Authorization", "Bearer "+c.GitHubToken)
+		}
+
+		if !c.budget.take() {
+			return nil, errBudgetExceeded
+		}
+
+		if c.Limiter != nil {
+			if err := c.Limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		c.logger().Debug("fetching", "url", url, "attempt", attempt+1)
+		resp, err := c.HTTPClient.Do(req)
+		c.stats.countRequest(resp)
+		if err == nil && !retryableStatus(resp.StatusCode) {
+			c.logger().Debug("fetched", "url", url, "status", resp.StatusCode)
+			return resp, nil
+		}
+		if attempt >= len(schedule) {
+			return resp, err
+		}
+		wait := withJitter(schedule[attempt])
+		if err == nil {
+			if after, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				wait = after
+			}
+			c.logger().Info("retrying request", "url", url, "status", resp.StatusCode, "wait", wait)
+			resp.Body.Close()
+		} else {
+			c.logger().Info("retrying request", "url", url, "err", err, "wait", wait)
+		}
+
+		if err := c.wait(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// wait blocks for d before a retry, returning the error of ctx if it is
+// cancelled first.
+func (c *Client) wait(ctx context.Context, d time.Duration) error {
+	if c.sleep != nil {
+		return c.sleep(ctx, d)
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// retryAfter parses a Retry-After header, given either in seconds or as an
+// HTTP date, into the time to wait from now.
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// retryableStatus reports whether a response status indicates a transient
+// failure that is worth retrying.
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// exponentialBackoff returns a schedule of retries intervals starting at base
+// and doubling each time.
+func exponentialBackoff(retries int, base time.Duration) []time.Duration {
+	schedule := make([]time.Duration, retries)
+	for i := range schedule {
+		schedule[i] = base << i
+	}
+	return schedule
+}
+
+// withJitter adds a random delay of up to half of d, so that clients that
+// failed at the same time do not all retry at the same time.
+func withJitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return d
+	}
+	return d + rand.N(d/2+1)
+}
+
+// parseBackoff parses a comma-separated list of durations such as
+// "1s,3s,10s" into a backoff schedule.
+func parseBackoff(value string) ([]time.Duration, error) {
+	schedule := []time.Duration{}
+	for _, part := range strings.Split(value, ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid backoff interval %q: %w", part, err)
+		}
+		schedule = append(schedule, d)
+	}
+	return schedule, nil
+}
+
+// nextURL returns the absolute URL of the page following current, or an empty
+// string if there are no more pages.
+func (c *Client) nextURL(current string, meta registryMeta) string {
+	next := meta.NextURL
+	if next == "" {
+		return ""
+	}
+	if !strings.Contains(next, "://") {
+		next = c.BaseURL + next
+	}
+	if next == current {
+		return ""
+	}
+	return next
+}
+
+// errBudgetExceeded is returned by every request once the request budget
+// of the client has been used up.
+var errBudgetExceeded = errors.New("registry request budget exceeded")
+
+// requestBudget counts the requests made by a client against a maximum.
+type requestBudget struct {
+	max  int64
+	used atomic.Int64
+}
+
+// newRequestBudget returns a budget of max requests.
+func newRequestBudget(max int) *requestBudget {
+	return &requestBudget{max: int64(max)}
+}
+
+// take uses up one request of the budget, reporting false if none are left.
+// A nil budget is unlimited.
+func (b *requestBudget) take() bool {
+	return b == nil || b.used.Add(1) <= b.max
+}
+
+// ErrHostNotAllowed is returned by lookups of sources whose host is not in
+// AllowedHosts.
+var ErrHostNotAllowed = errors.New("host not allowed")
+
+// checkHost returns ErrHostNotAllowed if any of hosts is not in AllowedHosts.
+func (c *Client) checkHost(hosts ...string) error {
+	if len(c.AllowedHosts) == 0 {
+		return nil
+	}
+
+	for _, host := range hosts {
+		allowed := false
+		for _, h := range c.AllowedHosts {
+			if strings.EqualFold(strings.TrimSpace(h), host) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return ErrHostNotAllowed
+		}
+	}
+	return nil
+}
+
+// registryHost returns the host of the registry at BaseURL.
+func (c *Client) registryHost() string {
+	u, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// ErrNoVersions is returned by the latest version lookups when the registry
+// lists no usable version at all.
+var ErrNoVersions = errors.New("no versions found")
+
+// sortVersions parses versions, ignoring entries that do not parse, and sorts
+// them from newest to oldest. It returns ErrNoVersions if none parse.
+func sortVersions(versions []string) ([]*semver.Version, error) {
+	var validVersions []*semver.Version
+	for _, v := range versions {
+		if version, err := semver.NewVersion(v); err == nil {
+			validVersions = append(validVersions, version)
+		}
+	}
+
+	if len(validVersions) == 0 {
+		return nil, ErrNoVersions
+	}
+
+	sort.Slice(validVersions, func(i, j int) bool {
+		return validVersions[i].GreaterThan(validVersions[j])
+	})
+
+	return validVersions, nil
+}
@@ -0,0 +1,286 @@
+package tfridge
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLatestModuleVersion(t *testing.T) {
+	registry := newFakeRegistry(t, map[string]string{
+		"/v1/modules/terraform-aws-modules/vpc/aws/versions":    `{"modules":[{"versions":[{"version":"4.0.0"},{"version":"5.1.2"},{"version":"bad"}]}],"meta":{"next_url":"/v1/modules/terraform-aws-modules/vpc/aws/versions/p2"}}`,
+		"/v1/modules/terraform-aws-modules/vpc/aws/versions/p2": `{"modules":[{"versions":[{"version":"5.2.0"},{"version":"5.10.0"}]}]}`,
+	})
+
+	latest, err := registry.client().LatestModuleVersion(context.Background(), "terraform-aws-modules/vpc/aws//modules/endpoints?ref=x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest != "5.10.0" {
+		t.Errorf("got latest version %s, want 5.10.0 from the second page", latest)
+	}
+}
+
+func TestLatestModuleVersionNoVersions(t *testing.T) {
+	registry := newFakeRegistry(t, map[string]string{
+		"/v1/modules/acme/empty/aws/versions": `{"modules":[{"versions":[{"version":"not-a-version"}]}]}`,
+	})
+
+	_, err := registry.client().LatestModuleVersion(context.Background(), "acme/empty/aws")
+	if !errors.Is(err, ErrNoVersions) {
+		t.Errorf("got error %v, want ErrNoVersions", err)
+	}
+}
+
+func TestLatestModuleVersionNotFound(t *testing.T) {
+	registry := newFakeRegistry(t, nil)
+
+	_, err := registry.client().LatestModuleVersion(context.Background(), "acme/missing/aws")
+	if err == nil || errors.Is(err, ErrNoVersions) {
+		t.Errorf("got error %v, want a failed lookup", err)
+	}
+}
+
+func TestLatestProviderVersion(t *testing.T) {
+	registry := newFakeRegistry(t, map[string]string{
+		"/v1/providers/hashicorp/null/versions": `{"versions":[{"version":"2.1.0","protocols":["4.0"]},{"version":"3.0.0","protocols":["4.0","5.0"]},{"version":"3.2.0","protocols":["6.0"]}]}`,
+	})
+
+	tests := []struct {
+		source    string
+		protocols []int
+		want      string
+	}{
+		{"hashicorp/null", nil, "3.2.0"},
+		{"null", nil, "3.2.0"},
+		{"hashicorp/null", []int{5}, "3.0.0"},
+		{"hashicorp/null", []int{4}, "3.0.0"},
+	}
+	for _, tt := range tests {
+		client := registry.client()
+		client.ProviderProtocols = tt.protocols
+		latest, err := client.LatestProviderVersion(context.Background(), tt.source)
+		if err != nil {
+			t.Errorf("%s with protocols %v: %v", tt.source, tt.protocols, err)
+			continue
+		}
+		if latest != tt.want {
+			t.Errorf("%s with protocols %v: got %s, want %s", tt.source, tt.protocols, latest, tt.want)
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	registry := newFakeRegistry(t, map[string]string{
+		"/.well-known/terraform.json":           `{"modules.v1":"/api/modules/","providers.v1":"/api/providers/"}`,
+		"/api/providers/hashicorp/aws/versions": `{"versions":[{"version":"5.0.0"}]}`,
+	})
+
+	client := registry.client()
+	if err := client.Discover(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if client.ProvidersURL != registry.URL+"/api/providers" {
+		t.Errorf("got providers URL %s", client.ProvidersURL)
+	}
+
+	latest, err := client.LatestProviderVersion(context.Background(), "hashicorp/aws")
+	if err != nil || latest != "5.0.0" {
+		t.Errorf("got %q, %v; want 5.0.0 from the discovered path", latest, err)
+	}
+}
+
+// flakyServer answers the first failures requests with status, and any later
+// one with a single provider version. It counts the requests made.
+func flakyServer(t *testing.T, failures int, status int, retryAfter string) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= int64(failures) {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+			return
+		}
+		fmt.Fprint(w, `{"versions":[{"version":"1.0.0"}]}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+// recordingClient returns a client for srv retrying after each interval of
+// backoff, which records the waits instead of sleeping.
+func recordingClient(srv *httptest.Server, backoff string) (*Client, *[]time.Duration) {
+	client := NewClient(srv.URL)
+	client.HTTPClient = srv.Client()
+	client.Logger = newLogger(io.Discard, slog.LevelWarn)
+	client.Backoff, _ = parseBackoff(backoff)
+
+	var waits []time.Duration
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return client, &waits
+}
+
+// checkWaits checks that every wait is the interval of the schedule it was
+// made for, plus a jitter of at most half of it.
+func checkWaits(t *testing.T, waits, schedule []time.Duration) {
+	t.Helper()
+	if len(waits) != len(schedule) {
+		t.Fatalf("got %d waits %v, want %d", len(waits), waits, len(schedule))
+	}
+	for i, wait := range waits {
+		if wait < schedule[i] || wait > schedule[i]+schedule[i]/2 {
+			t.Errorf("retry %d waited %s, want %s plus at most half of it", i+1, wait, schedule[i])
+		}
+	}
+}
+
+func TestRetryBackoffSchedule(t *testing.T) {
+	srv, requests := flakyServer(t, 2, http.StatusServiceUnavailable, "")
+	client, waits := recordingClient(srv, "1s,3s,10s")
+
+	latest, err := client.LatestProviderVersion(context.Background(), "hashicorp/flaky")
+	if err != nil || latest != "1.0.0" {
+		t.Fatalf("got %q, %v; want 1.0.0 after two retries", latest, err)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("made %d requests, want 3", n)
+	}
+	checkWaits(t, *waits, []time.Duration{time.Second, 3 * time.Second})
+}
+
+func TestRetryBackoffExhausted(t *testing.T) {
+	srv, requests := flakyServer(t, 10, http.StatusBadGateway, "")
+	client, waits := recordingClient(srv, "1s,3s,10s")
+
+	if _, err := client.LatestProviderVersion(context.Background(), "hashicorp/flaky"); err == nil {
+		t.Fatal("the lookup succeeded, want it to fail once the retries are used up")
+	}
+	if n := requests.Load(); n != 4 {
+		t.Errorf("made %d requests, want 4: one for each of the 3 intervals and the first", n)
+	}
+	checkWaits(t, *waits, []time.Duration{time.Second, 3 * time.Second, 10 * time.Second})
+}
+
+func TestRetryAfterHeader(t *testing.T) {
+	srv, _ := flakyServer(t, 1, http.StatusTooManyRequests, "7")
+	client, waits := recordingClient(srv, "1s")
+
+	if _, err := client.LatestProviderVersion(context.Background(), "hashicorp/flaky"); err != nil {
+		t.Fatal(err)
+	}
+	if len(*waits) != 1 || (*waits)[0] != 7*time.Second {
+		t.Errorf("got waits %v, want the 7s from Retry-After", *waits)
+	}
+}
+
+func TestNoRetryWithEmptyBackoff(t *testing.T) {
+	srv, requests := flakyServer(t, 1, http.StatusServiceUnavailable, "")
+	client, waits := recordingClient(srv, "1s")
+	client.Backoff = []time.Duration{}
+
+	if _, err := client.LatestProviderVersion(context.Background(), "hashicorp/flaky"); err == nil {
+		t.Fatal("the lookup succeeded without retrying")
+	}
+	if n := requests.Load(); n != 1 || len(*waits) != 0 {
+		t.Errorf("made %d requests and waited %v, want a single request", n, *waits)
+	}
+}
+
+func TestLatestVersionSkipsDeprecated(t *testing.T) {
+	registry := newFakeRegistry(t, map[string]string{
+		"/v1/modules/acme/network/aws/versions": `{"modules":[{"versions":[{"version":"2.0.0"},{"version":"2.1.0","deprecation":{"reason":"breaks routing"}},{"version":"2.0.1"}]}]}`,
+		"/v1/providers/acme/cloud/versions":     `{"versions":[{"version":"1.4.0"},{"version":"1.5.0","deprecation":{"reason":"withdrawn"}}]}`,
+	})
+
+	tests := []struct {
+		includeDeprecated bool
+		module, provider  string
+	}{
+		{false, "2.0.1", "1.4.0"},
+		{true, "2.1.0", "1.5.0"},
+	}
+	for _, tt := range tests {
+		client := registry.client()
+		client.IncludeDeprecated = tt.includeDeprecated
+
+		module, err := client.LatestModuleVersion(context.Background(), "acme/network/aws")
+		if err != nil || module != tt.module {
+			t.Errorf("with IncludeDeprecated %v: got module version %q, %v; want %s", tt.includeDeprecated, module, err, tt.module)
+		}
+		provider, err := client.LatestProviderVersion(context.Background(), "acme/cloud")
+		if err != nil || provider != tt.provider {
+			t.Errorf("with IncludeDeprecated %v: got provider version %q, %v; want %s", tt.includeDeprecated, provider, err, tt.provider)
+		}
+	}
+}
+
+func TestLookupLogsCacheHits(t *testing.T) {
+	registry := newFakeRegistry(t, map[string]string{
+		"/v1/providers/hashicorp/aws/versions": `{"versions":[{"version":"5.0.0"}]}`,
+	})
+	var logs bytes.Buffer
+	client := registry.client()
+	client.Logger = newLogger(&logs, slog.LevelDebug)
+	client.cache = newReleaseCache()
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := client.dependencyReleases(context.Background(), kindProvider, "hashicorp/aws"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := registry.requestCount(); n != 1 {
+		t.Errorf("made %d requests, want the second lookup answered from the cache", n)
+	}
+	miss := strings.Index(logs.String(), `msg="cache miss" key=provider:hashicorp/aws`)
+	hit := strings.Index(logs.String(), `msg="cache hit" key=provider:hashicorp/aws`)
+	if miss < 0 || hit < miss {
+		t.Errorf("got logs:\n%s\nwant a cache miss and then a cache hit", logs.String())
+	}
+}
+
+func TestLookupsStripTheRegistryHost(t *testing.T) {
+	registry := newFakeRegistry(t, map[string]string{
+		"/v1/modules/acme/network/aws/versions":   `{"modules":[{"versions":[{"version":"1.0.0"}]}]}`,
+		"/v1/providers/hashicorp/random/versions": `{"versions":[{"version":"3.6.0"}]}`,
+	})
+	client := registry.client()
+	client.BaseURL = "https://tf.example.com"
+	client.ModulesURL = registry.URL + "/v1/modules"
+	client.ProvidersURL = registry.URL + "/v1/providers"
+
+	for _, source := range []string{"tf.example.com/acme/network/aws", "registry.terraform.io/acme/network/aws//modules/vpc"} {
+		if latest, err := client.LatestModuleVersion(context.Background(), source); err != nil || latest != "1.0.0" {
+			t.Errorf("%s: got %q, %v; want 1.0.0", source, latest, err)
+		}
+	}
+	for _, source := range []string{"TF.example.com/hashicorp/random", "registry.terraform.io/hashicorp/random", "registry.opentofu.org/hashicorp/random"} {
+		if latest, err := client.LatestProviderVersion(context.Background(), source); err != nil || latest != "3.6.0" {
+			t.Errorf("%s: got %q, %v; want 3.6.0", source, latest, err)
+		}
+	}
+
+	before := registry.requestCount()
+	if _, err := client.LatestProviderVersion(context.Background(), "tf.other.com/hashicorp/random"); err == nil {
+		t.Error("a provider on another private registry was looked up on the one queried")
+	}
+	if _, err := client.LatestModuleVersion(context.Background(), "tf.other.com/acme/network/aws"); err == nil {
+		t.Error("a module on another private registry was looked up on the one queried")
+	}
+	if n := registry.requestCount() - before; n != 0 {
+		t.Errorf("made %d requests for sources on another registry, want none", n)
+	}
+}
@@ -0,0 +1,53 @@
+package tfridge
+
+import (
+	"fmt"
+	"strings"
+)
+
+// rewriteRule replaces the From prefix of a source with To before its
+// versions are looked up.
+type rewriteRule struct {
+	From string
+	To   string
+}
+
+// parseRewrites parses --rewrite values of the form from=to.
+func parseRewrites(values []string) ([]rewriteRule, error) {
+	var rules []rewriteRule
+	for _, value := range values {
+		from, to, ok := strings.Cut(value, "=")
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("rewrite %q is not of the form from=to", value)
+		}
+		rules = append(rules, rewriteRule{From: from, To: to})
+	}
+	return rules, nil
+}
+
+// apply returns source with the rule applied, and whether it matched. The
+// prefix must end at a path separator, so that a rule for "mirror.corp/tf/vpc"
+// does not apply to "mirror.corp/tf/vpc2".
+func (r rewriteRule) apply(source string) (string, bool) {
+	rest, ok := strings.CutPrefix(source, r.From)
+	if !ok || (rest != "" && !strings.HasPrefix(rest, "/") && !strings.HasSuffix(r.From, "/")) {
+		return source, false
+	}
+	return r.To + rest, true
+}
+
+// applyRewrites rewrites the lookup address of every dependency matched by
+// one of rules. The first matching rule wins.
+func applyRewrites(result *scanResult, rules []rewriteRule) {
+	for _, deps := range []map[string]*dependency{result.moduleMap, result.providerMap} {
+		for _, dep := range deps {
+			for _, rule := range rules {
+				if lookup, ok := rule.apply(dep.lookupSource()); ok {
+					logger.Debug("rewriting source", "source", dep.lookupSource(), "lookup", lookup)
+					dep.Lookup = lookup
+					break
+				}
+			}
+		}
+	}
+}
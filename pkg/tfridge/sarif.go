@@ -0,0 +1,170 @@
+package tfridge
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// sarifRules lists the rules that results can be reported under.
+var sarifRules = []sarifRule{
+	{ID: "tfridge/outdated-module", ShortDescription: sarifMessage{Text: "A newer version of this module is available"}},
+	{ID: "tfridge/outdated-provider", ShortDescription: sarifMessage{Text: "A newer version of this provider is available"}},
+	{ID: "tfridge/outdated-core", ShortDescription: sarifMessage{Text: "The Terraform required_version floor is behind the latest Terraform release"}},
+	{ID: "tfridge/below-target", ShortDescription: sarifMessage{Text: "This declaration is below its configured target version"}},
+	{ID: "tfridge/unpinned", ShortDescription: sarifMessage{Text: "No version constraint is declared for this module or provider"}},
+	{ID: "tfridge/unapproved-host", ShortDescription: sarifMessage{Text: "This module is fetched from a host that is not approved"}},
+	{ID: "tfridge/unused-provider", ShortDescription: sarifMessage{Text: "This provider is required but not used by any resource or data source"}},
+}
+
+// renderSARIF writes a SARIF 2.1.0 document with one result per outdated
+// module or provider, located at each place it is declared, and one per unused
+// provider requirement.
+func renderSARIF(w io.Writer, rep *report) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "tfridge",
+			Version:        appVersion,
+			InformationURI: "https://github.com/eisraeli/tfridge",
+			Rules:          sarifRules,
+		}},
+		Results: []sarifResult{},
+	}
+
+	for _, r := range append(rep.Results, rep.Core...) {
+		if r.Err != nil {
+			continue
+		}
+
+		if r.unpinned() {
+			for _, decl := range r.Dependency.Declarations {
+				run.Results = append(run.Results, sarifResult{
+					RuleID:    "tfridge/unpinned",
+					Level:     "warning",
+					Message:   sarifMessage{Text: fmt.Sprintf("%s %s has no version constraint; the latest version is %s", kindLabel(r.Kind), decl.Source, r.Latest)},
+					Locations: sarifLocations(rep.RootPath, decl.File, decl.Line),
+				})
+			}
+			continue
+		}
+
+		for _, decl := range r.outdatedDeclarations("") {
+			run.Results = append(run.Results, sarifResult{
+				RuleID: "tfridge/outdated-" + r.Kind,
+				Level:  "warning",
+				Message: sarifMessage{Text: fmt.Sprintf("%s %s is at version %s; the latest version is %s",
+					kindLabel(r.Kind), decl.Source, decl.Version, r.wanted())},
+				Locations: sarifLocations(rep.RootPath, decl.File, decl.Line),
+			})
+		}
+	}
+
+	for _, tc := range rep.Targets {
+		if tc.AtTarget {
+			continue
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    "tfridge/below-target",
+			Level:     "warning",
+			Message:   sarifMessage{Text: tc.message()},
+			Locations: sarifLocations(rep.RootPath, tc.Declaration.File, tc.Declaration.Line),
+		})
+	}
+
+	for _, v := range rep.HostViolations {
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    "tfridge/unapproved-host",
+			Level:     "error",
+			Message:   sarifMessage{Text: v.message()},
+			Locations: sarifLocations(rep.RootPath, v.Declaration.File, v.Declaration.Line),
+		})
+	}
+
+	for _, req := range rep.UnusedProviders {
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    "tfridge/unused-provider",
+			Level:     "note",
+			Message:   sarifMessage{Text: unusedProviderMessage(req)},
+			Locations: sarifLocations(rep.RootPath, req.File, req.Line),
+		})
+	}
+
+	return writeJSON(w, sarifLog{Version: sarifVersion, Schema: sarifSchema, Runs: []sarifRun{run}})
+}
+
+// sarifLocations returns the location of a line in a file.
+func sarifLocations(rootPath, file string, line int) []sarifLocation {
+	return []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: sarifURI(rootPath, file)},
+		Region:           sarifRegion{StartLine: line},
+	}}}
+}
+
+// sarifURI returns the path of a file relative to the scan root, using forward
+// slashes as SARIF requires.
+func sarifURI(rootPath, file string) string {
+	if rel, err := filepath.Rel(rootPath, file); err == nil {
+		file = rel
+	}
+	return filepath.ToSlash(file)
+}
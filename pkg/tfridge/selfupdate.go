@@ -0,0 +1,144 @@
+package tfridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// The repository tfridge releases are published in.
+const (
+	selfOwner = "eisraeli"
+	selfRepo  = "tfridge"
+)
+
+const (
+	// selfUpdateInterval is how long the latest release found is remembered
+	// before GitHub is asked again.
+	selfUpdateInterval = 24 * time.Hour
+
+	// selfUpdateTimeout bounds the check, so that it never holds up a run
+	// for long.
+	selfUpdateTimeout = 2 * time.Second
+)
+
+// githubRelease is the part of a /repos/:owner/:repo/releases/latest
+// response that is used.
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+}
+
+// selfUpdateState is the cached result of the last check, stored in the user
+// cache directory.
+type selfUpdateState struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Latest    string    `json:"latest"`
+}
+
+// latestGitHubRelease returns the tag of the latest release of a GitHub
+// repository.
+func (c *Client) latestGitHubRelease(ctx context.Context, owner, repo string) (string, error) {
+	if err := c.checkHost("github.com"); err != nil {
+		return "", err
+	}
+
+	url := fmt.Sprintf("%s/repos/%s/%s/releases/latest", c.GitHubURL, owner, repo)
+	var release githubRelease
+	if err := c.getJSON(ctx, url, &release, "failed to fetch the latest release"); err != nil {
+		return "", err
+	}
+	return release.TagName, nil
+}
+
+// selfUpdateStatePath returns the file the last check is cached in.
+func selfUpdateStatePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tfridge", "self-update.json"), nil
+}
+
+// latestSelfVersion returns the latest tfridge release, from the cache at
+// path if it was checked less than selfUpdateInterval before now, or else
+// from GitHub, in which case the cache is updated.
+func latestSelfVersion(ctx context.Context, client *Client, path string, now time.Time) (string, error) {
+	var state selfUpdateState
+	if data, err := os.ReadFile(path); err == nil && json.Unmarshal(data, &state) == nil {
+		if now.Sub(state.CheckedAt) < selfUpdateInterval && state.Latest != "" {
+			return state.Latest, nil
+		}
+	}
+
+	latest, err := client.latestGitHubRelease(ctx, selfOwner, selfRepo)
+	if err != nil {
+		return "", err
+	}
+
+	state = selfUpdateState{CheckedAt: now, Latest: latest}
+	data, err := json.Marshal(state)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0o755)
+	}
+	if err == nil {
+		err = os.WriteFile(path, data, 0o644)
+	}
+	if err != nil {
+		logger.Debug("could not cache the latest tfridge release", "path", path, "err", err)
+	}
+	return latest, nil
+}
+
+// startSelfUpdateCheck looks up the latest tfridge release in the background.
+// The returned channel receives the latest release if it is newer than the
+// running version, and is closed once the check is done.
+func startSelfUpdateCheck(ctx context.Context, opts options) <-chan string {
+	notice := make(chan string, 1)
+
+	// Like any other lookup, the check is only made if github.com may be
+	// contacted. It is not retried and does not count against the budget.
+	client := *DefaultClient
+	client.Backoff = []time.Duration{}
+	client.GitHubToken = os.Getenv("GITHUB_TOKEN")
+	client.Logger = logger
+	client.AllowedHosts = opts.allowedHosts
+
+	go func() {
+		defer close(notice)
+		ctx, cancel := context.WithTimeout(ctx, selfUpdateTimeout)
+		defer cancel()
+
+		current, err := semver.NewVersion(appVersion)
+		if err != nil {
+			return
+		}
+		path, err := selfUpdateStatePath()
+		if err != nil {
+			logger.Debug("no cache directory for the self-update check", "err", err)
+			return
+		}
+		latest, err := latestSelfVersion(ctx, &client, path, time.Now())
+		if err != nil {
+			logger.Debug("could not check for a newer tfridge release", "err", err)
+			return
+		}
+		if v, err := semver.NewVersion(latest); err == nil && v.GreaterThan(current) {
+			notice <- latest
+		}
+	}()
+	return notice
+}
+
+// printSelfUpdateNotice waits for the self-update check to finish and, if a
+// newer release was found, says so on w.
+func printSelfUpdateNotice(w io.Writer, notice <-chan string) {
+	if latest := <-notice; latest != "" {
+		fmt.Fprintf(w, "A newer version of tfridge is available: %s (running %s)\n", latest, appVersion)
+	}
+}
@@ -0,0 +1,217 @@
+package tfridge
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// defaultRegistryHost is the host of registry addresses that do not name one.
+const defaultRegistryHost = "registry.terraform.io"
+
+// registryNameRegex matches a single namespace, name or provider segment of a
+// registry address.
+var registryNameRegex = regexp.MustCompile(`^[0-9A-Za-z](?:[0-9A-Za-z_-]*[0-9A-Za-z])?$`)
+
+// registryHostRegex matches the optional hostname prefix of a registry address.
+var registryHostRegex = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z.-]*\.[0-9A-Za-z-]+(?::[0-9]+)?$`)
+
+// remoteSourcePrefixes are the module source forms that are fetched directly
+// from a VCS, bucket or URL instead of a registry.
+var remoteSourcePrefixes = []string{
+	"github.com/",
+	"bitbucket.org/",
+	"git@",
+}
+
+// isLocalSource reports whether a module source refers to a local path.
+func isLocalSource(source string) bool {
+	return strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../")
+}
+
+// isRemoteSource reports whether a module source is fetched from somewhere
+// other than a registry, such as a git repository, bucket or URL.
+func isRemoteSource(source string) bool {
+	if strings.Contains(source, "::") || strings.Contains(source, "://") {
+		return true
+	}
+	for _, prefix := range remoteSourcePrefixes {
+		if strings.HasPrefix(source, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Kinds of module source, as returned by sourceKind.
+const (
+	sourceRegistry = "registry"
+	sourceLocal    = "local"
+	sourceRemote   = "remote"
+	sourceDynamic  = "dynamic"
+	sourceInvalid  = "invalid"
+)
+
+// sourceKind classifies a module source by how it can be resolved.
+func sourceKind(source string) string {
+	switch {
+	case strings.Contains(source, "${"):
+		return sourceDynamic
+	case isLocalSource(source):
+		return sourceLocal
+	case isRemoteSource(source):
+		return sourceRemote
+	case validateModuleSource(source) != nil:
+		return sourceInvalid
+	default:
+		return sourceRegistry
+	}
+}
+
+// moduleHost returns the host a module source is fetched from, or an empty
+// string for local and dynamic sources and sources whose host cannot be
+// determined. Registry addresses without a host resolve to registryHost.
+func moduleHost(source, registryHost string) string {
+	switch sourceKind(source) {
+	case sourceRegistry:
+		address, _ := splitSubdir(source)
+		if parts := strings.Split(address, "/"); len(parts) == 4 {
+			return strings.ToLower(parts[0])
+		}
+		return registryHost
+	case sourceLocal, sourceDynamic, sourceInvalid:
+		return ""
+	}
+
+	// Strip a forced getter such as "git::" or "s3::"
+	if i := strings.Index(source, "::"); i >= 0 {
+		source = source[i+len("::"):]
+	}
+
+	// scp-like git addresses, such as "git@github.com:org/repo.git"
+	if strings.HasPrefix(source, "git@") {
+		host := strings.TrimPrefix(source, "git@")
+		if i := strings.IndexAny(host, ":/"); i >= 0 {
+			host = host[:i]
+		}
+		return strings.ToLower(host)
+	}
+
+	if !strings.Contains(source, "://") {
+		source = "https://" + source
+	}
+	u, err := url.Parse(source)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// providerHost returns the registry host a provider source is published on,
+// which is registryHost for sources without a host.
+func providerHost(source, registryHost string) string {
+	if parts := strings.Split(source, "/"); len(parts) == 3 {
+		return strings.ToLower(parts[0])
+	}
+	return registryHost
+}
+
+// splitSubdir splits a module source into the package address and the
+// subdirectory within it, as in "namespace/name/provider//modules/vpc". The
+// "//" of a URL scheme is not treated as a subdirectory separator.
+func splitSubdir(source string) (string, string) {
+	offset := 0
+	if i := strings.Index(source, "://"); i >= 0 {
+		offset = i + len("://")
+	}
+
+	i := strings.Index(source[offset:], "//")
+	if i < 0 {
+		return source, ""
+	}
+	return source[:offset+i], source[offset+i+len("//"):]
+}
+
+// moduleAddress returns the address of the package a module source refers to,
+// without its subdirectory or query string, so that different subdirectories
+// and refs of the same package are looked up only once.
+func moduleAddress(source string) string {
+	if i := strings.Index(source, "?"); i >= 0 {
+		source = source[:i]
+	}
+	address, _ := splitSubdir(source)
+	return address
+}
+
+// normalizeModuleAddress returns the address a registry module is identified
+// by: lowercased, since the registry compares addresses case-insensitively,
+// and without trailing slashes. Other sources are returned unchanged.
+func normalizeModuleAddress(address string) string {
+	trimmed := strings.TrimRight(address, "/")
+	if sourceKind(trimmed) != sourceRegistry {
+		return address
+	}
+	return strings.ToLower(trimmed)
+}
+
+// normalizeProviderAddress returns the address a provider is identified by,
+// lowercased and without surrounding slashes like a registry module address.
+func normalizeProviderAddress(address string) string {
+	return strings.ToLower(strings.Trim(address, "/"))
+}
+
+// validateModuleSource checks that a module source is either a local path, a
+// remote source or a well-formed registry address.
+func validateModuleSource(source string) error {
+	if strings.ContainsAny(source, " \t") {
+		return fmt.Errorf("module source %q contains whitespace", source)
+	}
+	if strings.Contains(source, "${") {
+		return fmt.Errorf("module source %q is a dynamic source (not resolvable)", source)
+	}
+	if isLocalSource(source) || isRemoteSource(source) {
+		return nil
+	}
+
+	address, _ := splitSubdir(source)
+	parts := strings.Split(strings.TrimRight(address, "/"), "/")
+	if len(parts) == 4 {
+		if !registryHostRegex.MatchString(parts[0]) {
+			return fmt.Errorf("module source %q has an invalid registry host %q", source, parts[0])
+		}
+		parts = parts[1:]
+	}
+	if len(parts) != 3 {
+		return fmt.Errorf("module source %q is not a local path, remote source or <namespace>/<name>/<provider> registry address", source)
+	}
+	for _, part := range parts {
+		if !registryNameRegex.MatchString(part) {
+			return fmt.Errorf("module source %q has an invalid segment %q", source, part)
+		}
+	}
+
+	return nil
+}
+
+// validateProviderSource checks that a provider source is a well-formed
+// [<host>/][<namespace>/]<type> address.
+func validateProviderSource(source string) error {
+	parts := strings.Split(strings.Trim(source, "/"), "/")
+	if len(parts) == 3 {
+		if !registryHostRegex.MatchString(parts[0]) {
+			return fmt.Errorf("provider source %q has an invalid registry host %q", source, parts[0])
+		}
+		parts = parts[1:]
+	}
+	if len(parts) > 3 {
+		return fmt.Errorf("provider source %q has too many segments", source)
+	}
+	for _, part := range parts {
+		if !registryNameRegex.MatchString(part) {
+			return fmt.Errorf("provider source %q has an invalid segment %q", source, part)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package tfridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// modulesManifest is the .terraform/modules/modules.json file written by
+// terraform init, which lists every module package it installed.
+type modulesManifest struct {
+	Modules []struct {
+		Key     string `json:"Key"`
+		Source  string `json:"Source"`
+		Version string `json:"Version"`
+	} `json:"Modules"`
+}
+
+// showOutput is the part of the output of terraform show -json, for either a
+// state or a plan, that is used.
+type showOutput struct {
+	FormatVersion string       `json:"format_version"`
+	Values        *stateValues `json:"values"`
+	PlannedValues *stateValues `json:"planned_values"`
+	Configuration *struct {
+		ProviderConfig map[string]struct {
+			FullName          string `json:"full_name"`
+			VersionConstraint string `json:"version_constraint"`
+		} `json:"provider_config"`
+		RootModule configModule `json:"root_module"`
+	} `json:"configuration"`
+}
+
+// stateValues holds the resources of a state, by module.
+type stateValues struct {
+	RootModule stateModule `json:"root_module"`
+}
+
+// stateModule is a module instance in a state and the resources in it.
+type stateModule struct {
+	Resources []struct {
+		Address      string `json:"address"`
+		ProviderName string `json:"provider_name"`
+	} `json:"resources"`
+	ChildModules []stateModule `json:"child_modules"`
+}
+
+// configModule is a module in the configuration a plan was made from.
+type configModule struct {
+	ModuleCalls map[string]struct {
+		Source            string       `json:"source"`
+		VersionConstraint string       `json:"version_constraint"`
+		Module            configModule `json:"module"`
+	} `json:"module_calls"`
+}
+
+// publicRegistryAddress returns address without the host of a public
+// registry, which Terraform and OpenTofu prefix every module and provider
+// address from their own registry with in the files they write.
+func publicRegistryAddress(address string) string {
+	for _, base := range registryPresets {
+		host := strings.TrimPrefix(base, "https://")
+		if rest, ok := strings.CutPrefix(address, host+"/"); ok {
+			return rest
+		}
+	}
+	return address
+}
+
+// readModulesJSON extracts the module packages installed by terraform init,
+// with the exact versions that were resolved, from a modules.json file.
+func readModulesJSON(path string) (*scanResult, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest modulesManifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("%s is not a modules.json file: %w", path, err)
+	}
+
+	result := newScanResult()
+	result.files, result.walked = 1, 1
+	for _, module := range manifest.Modules {
+		// The root module has an empty key, and local modules are part of
+		// the configuration rather than installed packages
+		if module.Key == "" || isLocalSource(module.Source) {
+			continue
+		}
+		addModuleCall(result, publicRegistryAddress(module.Source), module.Version, path, jsonLineOf(content, module.Key))
+	}
+	return result, nil
+}
+
+// readState extracts the modules and providers of a state or plan from the
+// output of terraform show -json. Providers are taken from the resources in
+// the state, which record no version, and for a plan also from the provider
+// configurations with their version constraints. Modules are only listed in
+// the configuration of a plan, with the version constraints they were called
+// with.
+func readState(path string) (*scanResult, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var show showOutput
+	if err := json.Unmarshal(content, &show); err != nil || show.FormatVersion == "" {
+		return nil, fmt.Errorf("%s is not the output of terraform show -json", path)
+	}
+
+	result := newScanResult()
+	result.files, result.walked = 1, 1
+
+	if show.Configuration != nil {
+		for _, key := range sortedKeys(show.Configuration.ProviderConfig) {
+			provider := show.Configuration.ProviderConfig[key]
+			if provider.FullName == "" {
+				continue
+			}
+			address := normalizeProviderAddress(publicRegistryAddress(provider.FullName))
+			addDeclaration(result.providerMap, address, declaration{Source: provider.FullName, File: path, Line: jsonLineOf(content, provider.FullName), Version: provider.VersionConstraint})
+		}
+		addConfigModules(result, show.Configuration.RootModule, path, content)
+	}
+
+	values := show.Values
+	if values == nil {
+		values = show.PlannedValues
+	}
+	if values != nil {
+		addStateProviders(result, values.RootModule, path, content)
+	}
+	return result, nil
+}
+
+// addConfigModules records the module calls of module and of every module it
+// calls.
+func addConfigModules(result *scanResult, module configModule, path string, content []byte) {
+	for _, name := range sortedKeys(module.ModuleCalls) {
+		call := module.ModuleCalls[name]
+		addModuleCall(result, publicRegistryAddress(call.Source), call.VersionConstraint, path, jsonLineOf(content, name))
+		addConfigModules(result, call.Module, path, content)
+	}
+}
+
+// addStateProviders records the provider of every resource in module and its
+// child modules that is not already known.
+func addStateProviders(result *scanResult, module stateModule, path string, content []byte) {
+	for _, resource := range module.Resources {
+		if resource.ProviderName == "" {
+			continue
+		}
+		address := normalizeProviderAddress(publicRegistryAddress(resource.ProviderName))
+		if _, ok := result.providerMap[address]; !ok {
+			addDeclaration(result.providerMap, address, declaration{Source: resource.ProviderName, File: path, Line: jsonLineOf(content, resource.Address)})
+		}
+	}
+	for _, child := range module.ChildModules {
+		addStateProviders(result, child, path, content)
+	}
+}
+
+// sortedKeys returns the keys of m in order, so that declarations read from
+// a map are recorded in the same order on every run.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
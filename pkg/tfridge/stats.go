@@ -0,0 +1,91 @@
+package tfridge
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// scanStats counts the work done by a scan, as printed by --stats.
+type scanStats struct {
+	requests    atomic.Int64
+	bytes       atomic.Int64
+	cacheHits   atomic.Int64
+	cacheMisses atomic.Int64
+
+	walked  int
+	scanned int
+	scan    time.Duration
+	fetch   time.Duration
+	total   time.Duration
+}
+
+// countRequest records a request sent, and counts the bytes read from the
+// body of its response. A nil scanStats counts nothing.
+func (s *scanStats) countRequest(resp *http.Response) {
+	if s == nil {
+		return
+	}
+	s.requests.Add(1)
+	if resp != nil {
+		resp.Body = &countingBody{ReadCloser: resp.Body, n: &s.bytes}
+	}
+}
+
+// countCache records a lookup answered from the cache, or one that missed it.
+func (s *scanStats) countCache(hit bool) {
+	if s == nil {
+		return
+	}
+	if hit {
+		s.cacheHits.Add(1)
+	} else {
+		s.cacheMisses.Add(1)
+	}
+}
+
+// countingBody is a response body that adds the bytes read from it to n.
+type countingBody struct {
+	io.ReadCloser
+	n *atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
+
+// renderStats prints the counters and timings of a scan.
+func renderStats(w io.Writer, s *scanStats) {
+	fmt.Fprintf(w, "Stats:\n")
+	fmt.Fprintf(w, "  Files walked: %d (%d scanned)\n", s.walked, s.scanned)
+	fmt.Fprintf(w, "  HTTP requests: %d\n", s.requests.Load())
+	fmt.Fprintf(w, "  Cache: %d hits, %d misses\n", s.cacheHits.Load(), s.cacheMisses.Load())
+	fmt.Fprintf(w, "  Downloaded: %s\n", formatBytes(s.bytes.Load()))
+	fmt.Fprintf(w, "  Time: %s (scan %s, fetch %s)\n", roundDuration(s.total), roundDuration(s.scan), roundDuration(s.fetch))
+}
+
+// formatBytes formats a byte count with a binary unit.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, exp := float64(n)/unit, 0
+	for value >= unit && exp < 2 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMG"[exp])
+}
+
+// roundDuration rounds d to a precision that is readable at its magnitude.
+func roundDuration(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(time.Microsecond)
+}
@@ -0,0 +1,64 @@
+package tfridge
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStreamWritesBeforeLookupsComplete(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"main.tf": `
+module "alpha" {
+  source  = "acme/alpha/aws"
+  version = "1.0.0"
+}
+
+module "beta" {
+  source  = "acme/beta/aws"
+  version = "1.0.0"
+}
+`,
+	})
+
+	for _, stream := range []bool{true, false} {
+		output := filepath.Join(t.TempDir(), "report.txt")
+
+		// Record what had been written by the time the last lookup started
+		var written string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v1/modules/acme/alpha/aws/versions":
+			case "/v1/modules/acme/beta/aws/versions":
+				report, _ := os.ReadFile(output)
+				written = string(report)
+			default:
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprint(w, `{"modules":[{"versions":[{"version":"1.0.0"}]}]}`)
+		}))
+		t.Cleanup(srv.Close)
+		(&fakeRegistry{Server: srv}).useAsDefault(t)
+
+		args := []string{"-o", output, root}
+		if stream {
+			args = append([]string{"--stream"}, args...)
+		}
+		if err := runApp(t, args...); err != nil {
+			t.Fatal(err)
+		}
+
+		first := "Module source: acme/alpha/aws\n"
+		if got := strings.Contains(written, first); got != stream {
+			t.Errorf("with --stream %v: got the first result written before the last lookup %v, want %v:\n%s", stream, got, stream, written)
+		}
+		if report, _ := os.ReadFile(output); !strings.Contains(string(report), "Module source: acme/beta/aws\n") {
+			t.Errorf("with --stream %v: the report is missing the last result:\n%s", stream, report)
+		}
+	}
+}
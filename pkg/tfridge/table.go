@@ -0,0 +1,119 @@
+package tfridge
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+)
+
+// defaultMaxWidth is the default width past which sources are truncated in
+// --format table.
+const defaultMaxWidth = 60
+
+// renderTable prints the modules and providers as two aligned tables,
+// followed by the same sections as the text report. Sources longer than
+// maxWidth characters are truncated, unless maxWidth is 0.
+func renderTable(w io.Writer, rep *report, maxWidth int) {
+	render := func(w io.Writer, results []lookupResult) {
+		renderTables(w, results, maxWidth)
+	}
+	if rep.GroupByDir {
+		renderGroups(w, rep.Groups, render)
+	} else {
+		render(w, rep.Results)
+	}
+	renderSections(w, rep)
+}
+
+// renderTables prints a table of the modules and a table of the providers
+// among results.
+func renderTables(w io.Writer, results []lookupResult, maxWidth int) {
+	for _, kind := range []string{kindModule, kindProvider} {
+		var rows []lookupResult
+		for _, r := range results {
+			if r.Kind == kind {
+				rows = append(rows, r)
+			}
+		}
+		if len(rows) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(w, "%ss:\n", kindLabel(kind))
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "  Source\tCurrent\tLatest\tStatus\n")
+		for _, r := range rows {
+			sources := strings.Join(r.Dependency.declaredSources(), ", ")
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t", truncate(sources, maxWidth), orDash(r.Dependency.Version), orDash(r.Latest))
+			if status, c := tableStatus(r); c != nil {
+				c.Fprintln(tw, status)
+			} else {
+				fmt.Fprintln(tw, status)
+			}
+		}
+		tw.Flush()
+		fmt.Fprintln(w)
+	}
+}
+
+// tableStatus summarizes a result for the status column of the table, and
+// returns the color to print it in like the "Latest version" line of the
+// text report.
+func tableStatus(r lookupResult) (string, *color.Color) {
+	var status string
+	switch {
+	case r.Err != nil:
+		return "lookup failed", nil
+	case r.Skipped:
+		return "skipped (host not allowed)", nil
+	case r.Dynamic:
+		return "dynamic source (not resolvable)", nil
+	case r.NotChecked:
+		return r.notCheckedNote(), nil
+	case r.NotFound:
+		return "not found in registry", nil
+	case r.unpinned():
+		return "unpinned", nil
+	case r.level() != "":
+		status = r.level() + " update"
+		if note := r.behindNote(); note != "" {
+			status += fmt.Sprintf(" (%s)", note)
+		}
+	case lowerBound(r.Dependency.Version) != nil:
+		status = "up to date"
+	default:
+		status = "unknown"
+	}
+
+	if note := r.pinnedNote(); note != "" {
+		status += ", " + note
+	}
+	if suggestion, ok := r.unpublished(); ok {
+		status += fmt.Sprintf(", current not published (did you mean %s?)", suggestion)
+	}
+	if bound := r.blockedBy(); bound != "" {
+		status += ", blocked by " + bound
+	}
+	return status, latestColor(r)
+}
+
+// truncate shortens s to at most max characters, ending it with an ellipsis
+// when anything was cut. A max of 0 leaves s unchanged.
+func truncate(s string, max int) string {
+	if max <= 0 || utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max-1]) + "…"
+}
+
+// orDash returns s, or "-" for an empty table cell.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,129 @@
+package tfridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// targetCheck compares a single declaration against the configured target
+// version for its source.
+type targetCheck struct {
+	Kind        string
+	Source      string
+	Target      string
+	Declaration declaration
+	// Known is false when the declared version could not be parsed.
+	Known    bool
+	AtTarget bool
+	// Unpublished is true when the registry was checked and does not have
+	// the target version.
+	Unpublished bool
+}
+
+// message describes the outcome of the check.
+func (t targetCheck) message() string {
+	msg := t.comparison()
+	if t.Unpublished {
+		msg += fmt.Sprintf(" (%s is not published)", t.Target)
+	}
+	return msg
+}
+
+// comparison describes how the declared version compares to the target.
+func (t targetCheck) comparison() string {
+	switch {
+	case !t.Known:
+		return fmt.Sprintf("%s %s version %q cannot be compared to target %s", kindLabel(t.Kind), t.Source, t.Declaration.Version, t.Target)
+	case t.AtTarget:
+		return fmt.Sprintf("%s %s %s is already at/above target %s", kindLabel(t.Kind), t.Source, t.Declaration.Version, t.Target)
+	default:
+		return fmt.Sprintf("%s %s %s is below target %s", kindLabel(t.Kind), t.Source, t.Declaration.Version, t.Target)
+	}
+}
+
+// checkTargets classifies every declaration of a source that has a target
+// version as already at/above or below that target, and checks with the
+// registry whether the target version is published.
+func checkTargets(ctx context.Context, client *Client, result *scanResult, targets map[string]string) []targetCheck {
+	var checks []targetCheck
+
+	check := func(kind string, deps map[string]*dependency, normalize func(string) string) {
+		// Targets may be given with any spelling of an address
+		normalized := make(map[string]string)
+		for source, target := range targets {
+			normalized[normalize(source)] = target
+		}
+
+		for _, dep := range sortedDependencies(deps) {
+			source := dep.Source
+			target, ok := normalized[source]
+			if !ok {
+				continue
+			}
+			targetVersion := semver.MustParse(target)
+
+			published, err := client.versionPublished(ctx, kind, dep.lookupSource(), targetVersion)
+			if err != nil {
+				logger.Info("could not check whether the target version is published", "source", source, "target", target, "err", err)
+			}
+
+			for _, decl := range dep.Declarations {
+				tc := targetCheck{Kind: kind, Source: decl.Source, Target: target, Declaration: decl, Unpublished: err == nil && !published}
+				if current := lowerBound(decl.Version); current != nil {
+					tc.Known = true
+					tc.AtTarget = !current.LessThan(targetVersion)
+				}
+				checks = append(checks, tc)
+			}
+		}
+	}
+
+	check(kindModule, result.moduleMap, normalizeModuleAddress)
+	check(kindProvider, result.providerMap, func(source string) string {
+		return canonicalProvider(source, client.defaultNamespace())
+	})
+
+	return checks
+}
+
+// versionPublished reports whether the registry has the given version of a
+// module or provider. Modules that are not in a registry are reported as
+// published, since there is nothing to check them against.
+func (c *Client) versionPublished(ctx context.Context, kind, source string, version *semver.Version) (bool, error) {
+	if kind == kindModule && sourceKind(source) != sourceRegistry {
+		return true, nil
+	}
+
+	// The versions are those already fetched to find the latest version
+	_, published, err := c.dependencyReleases(ctx, kind, source)
+	if err != nil && !errors.Is(err, ErrNoVersions) {
+		return false, err
+	}
+	for _, v := range published {
+		if v.Equal(version) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// parseTargets parses --target values of the form source=version.
+func parseTargets(values []string) (map[string]string, error) {
+	targets := make(map[string]string)
+	for _, value := range values {
+		i := strings.LastIndex(value, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("target %q is not of the form source=version", value)
+		}
+		source, version := value[:i], value[i+1:]
+		if _, err := semver.NewVersion(version); err != nil {
+			return nil, fmt.Errorf("target %q has an invalid version: %w", value, err)
+		}
+		targets[source] = version
+	}
+	return targets, nil
+}
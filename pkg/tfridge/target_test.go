@@ -0,0 +1,119 @@
+package tfridge
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCheckTargets(t *testing.T) {
+	registry := newFakeRegistry(t, map[string]string{
+		"/v1/modules/terraform-aws-modules/vpc/aws/versions": `{"modules":[{"versions":[{"version":"4.0.0"},{"version":"5.0.0"},{"version":"5.2.0"}]}]}`,
+		"/v1/providers/hashicorp/aws/versions":               `{"versions":[{"version":"5.0.0"},{"version":"6.1.0"}]}`,
+	})
+
+	root := writeFiles(t, map[string]string{
+		"a/main.tf": `
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "4.0.0"
+}
+`,
+		"b/main.tf": `
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "~> 5.2"
+}
+
+module "net" {
+  source = "./modules/net"
+}
+`,
+		"c/main.tf": `
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.0.0"
+}
+
+terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 5.0"
+    }
+  }
+}
+`,
+	})
+	result, err := scanDirectory(root, pathFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checks := checkTargets(context.Background(), registry.client(), result, map[string]string{
+		"Terraform-AWS-Modules/VPC/aws": "5.0.0",
+		"hashicorp/aws":                 "6.0.0",
+	})
+
+	want := []struct {
+		kind        string
+		version     string
+		atTarget    bool
+		unpublished bool
+	}{
+		{kindModule, "4.0.0", false, false},
+		{kindModule, "~> 5.2", true, false},
+		{kindModule, "5.0.0", true, false},
+		{kindProvider, "~> 5.0", false, true},
+	}
+	if len(checks) != len(want) {
+		t.Fatalf("got %d target checks, want %d: %+v", len(checks), len(want), checks)
+	}
+	for i, w := range want {
+		tc := checks[i]
+		if tc.Kind != w.kind || tc.Declaration.Version != w.version {
+			t.Errorf("check %d is %s %s, want %s %s", i, tc.Kind, tc.Declaration.Version, w.kind, w.version)
+			continue
+		}
+		if !tc.Known || tc.AtTarget != w.atTarget || tc.Unpublished != w.unpublished {
+			t.Errorf("%s %s: known %v, at target %v, unpublished %v; want known, at target %v, unpublished %v",
+				tc.Kind, w.version, tc.Known, tc.AtTarget, tc.Unpublished, w.atTarget, w.unpublished)
+		}
+	}
+
+	if got, want := checks[0].message(), "Module terraform-aws-modules/vpc/aws 4.0.0 is below target 5.0.0"; got != want {
+		t.Errorf("got message %q, want %q", got, want)
+	}
+}
+
+func TestCheckTargetsUnknownVersion(t *testing.T) {
+	registry := newFakeRegistry(t, map[string]string{
+		"/v1/providers/hashicorp/random/versions": `{"versions":[{"version":"3.6.0"}]}`,
+	})
+
+	result := newScanResult()
+	addDeclaration(result.providerMap, "hashicorp/random", declaration{Source: "hashicorp/random", File: "main.tf", Line: 3})
+
+	checks := checkTargets(context.Background(), registry.client(), result, map[string]string{"hashicorp/random": "3.6.0"})
+	if len(checks) != 1 {
+		t.Fatalf("got %d target checks, want 1", len(checks))
+	}
+	if checks[0].Known || checks[0].AtTarget {
+		t.Errorf("an unpinned declaration was classified against its target: %+v", checks[0])
+	}
+}
+
+func TestParseTargets(t *testing.T) {
+	targets, err := parseTargets([]string{"hashicorp/aws=5.40.0", "git::https://example.com/x.git?ref=v1=2.0.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if targets["hashicorp/aws"] != "5.40.0" || targets["git::https://example.com/x.git?ref=v1"] != "2.0.0" {
+		t.Errorf("got targets %v", targets)
+	}
+
+	for _, value := range []string{"hashicorp/aws", "=5.0.0", "hashicorp/aws=latest"} {
+		if _, err := parseTargets([]string{value}); err == nil {
+			t.Errorf("parseTargets(%q) succeeded, want an error", value)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package tfridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// jsonBody is a block in the JSON configuration syntax, keyed by argument or
+// nested block name.
+type jsonBody map[string]json.RawMessage
+
+// jsonBlocks decodes a value that is either a single block or, for repeated
+// blocks, an array of them.
+func jsonBlocks(raw json.RawMessage) []jsonBody {
+	var body jsonBody
+	if err := json.Unmarshal(raw, &body); err == nil {
+		return []jsonBody{body}
+	}
+
+	var bodies []jsonBody
+	if err := json.Unmarshal(raw, &bodies); err == nil {
+		return bodies
+	}
+	return nil
+}
+
+// jsonString returns the string argument name of body, or an empty string if
+// it is missing or not a string.
+func jsonString(body jsonBody, name string) string {
+	var value string
+	if raw, ok := body[name]; ok {
+		_ = json.Unmarshal(raw, &value)
+	}
+	return value
+}
+
+// jsonLineOf returns the line of the first occurrence of the JSON string name
+// in content, or 1 if there is none.
+func jsonLineOf(content []byte, name string) int {
+	i := bytes.Index(content, []byte(`"`+name+`"`))
+	if i < 0 {
+		return 1
+	}
+	return bytes.Count(content[:i], []byte("\n")) + 1
+}
+
+// extractJSON extracts the modules, providers and core version constraints
+// declared in a .tf.json file, the JSON form of the configuration syntax,
+// into result. JSON carries no line information, so each declaration is
+// attributed to the first line mentioning its name. JSON objects are read in
+// order of key, so that the declarations come out the same on every run.
+func extractJSON(filePath string, result *scanResult) error {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	var root jsonBody
+	if err := json.Unmarshal(content, &root); err != nil {
+		result.issues = append(result.issues, parseIssue{File: filePath, Line: 1, Message: fmt.Sprintf("invalid JSON configuration: %s", err)})
+		return nil
+	}
+
+	dir := filepath.Dir(filePath)
+	lineOf := func(name string) int {
+		return jsonLineOf(content, name)
+	}
+
+	for _, modules := range jsonBlocks(root["module"]) {
+		for _, name := range sortedKeys(modules) {
+			result.moduleCallDirs[dir] = true
+			line := lineOf(name)
+			for _, body := range jsonBlocks(modules[name]) {
+				var providers map[string]string
+				if raw, ok := body["providers"]; ok {
+					_ = json.Unmarshal(raw, &providers)
+				}
+				for _, child := range sortedKeys(providers) {
+					ref := moduleProviderRef{Module: name, File: filePath, Line: line, Child: child, Parent: providers[child]}
+					result.moduleProviderRefs = append(result.moduleProviderRefs, ref)
+					markProviderUsed(result, dir, ref.parentName())
+				}
+
+				addModuleCall(result, jsonString(body, "source"), jsonString(body, "version"), filePath, line)
+			}
+		}
+	}
+
+	for _, terraform := range jsonBlocks(root["terraform"]) {
+		if constraint := jsonString(terraform, "required_version"); constraint != "" {
+			addDeclaration(result.coreMap, constraint, declaration{Source: "required_version", File: filePath, Line: lineOf("required_version"), Version: constraint})
+		}
+
+		for _, requirements := range jsonBlocks(terraform["required_providers"]) {
+			for _, name := range sortedKeys(requirements) {
+				raw := requirements[name]
+				req := providerRequirement{Name: name, File: filePath, Line: lineOf(name)}
+				if err := json.Unmarshal(raw, &req.Version); err != nil {
+					// Not the legacy shorthand: name = "version constraint"
+					for _, body := range jsonBlocks(raw) {
+						req.Source = jsonString(body, "source")
+						req.Version = jsonString(body, "version")
+					}
+				}
+				addRequirement(result, req)
+			}
+		}
+	}
+
+	for _, providers := range jsonBlocks(root["provider"]) {
+		for _, name := range sortedKeys(providers) {
+			for _, body := range jsonBlocks(providers[name]) {
+				result.providerBlocks = append(result.providerBlocks, providerRequirement{Name: name, File: filePath, Line: lineOf(name), Version: jsonString(body, "version")})
+			}
+		}
+	}
+
+	for _, kind := range []string{"resource", "data"} {
+		for _, types := range jsonBlocks(root[kind]) {
+			for resourceType, raw := range types {
+				markProviderUsed(result, dir, strings.SplitN(resourceType, "_", 2)[0])
+				for _, instances := range jsonBlocks(raw) {
+					for _, raw := range instances {
+						for _, body := range jsonBlocks(raw) {
+							if provider := jsonString(body, "provider"); provider != "" {
+								markProviderUsed(result, dir, strings.SplitN(provider, ".", 2)[0])
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
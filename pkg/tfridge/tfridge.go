@@ -0,0 +1,1358 @@
+package tfridge
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v2"
+	"github.com/urfave/cli/v2/altsrc"
+	"golang.org/x/time/rate"
+)
+
+const appVersion = "0.0.1"
+
+// options holds the settings for a single scan, as parsed from the command line.
+type options struct {
+	rootPath        string
+	fromState       string
+	fromModules     string
+	parseOnly       bool
+	format          string
+	quiet           bool
+	checkCore       bool
+	moduleProviders bool
+	coverage        bool
+	plan            bool
+	failOnError     bool
+	failOnOutdated  string
+	failOnUnpinned  bool
+	approvedHosts   []string
+	stream          bool
+	level           string
+	registryURL     string
+	filter          pathFilter
+	rate            float64
+	allowedHosts    []string
+	output          string
+	rewrites        []rewriteRule
+	watch           bool
+	groupBy         string
+	respectCore     bool
+	maxRequests     int
+	stats           bool
+	namespace       string
+	offline         bool
+	vars            map[string]string
+	cache           *releaseCache
+	maxWidth        int
+	compare         string
+	previous        *Report
+	deprecated      bool
+	backoff         []time.Duration
+	config          *fileConfig
+}
+
+// inputFile returns the file given to --from-state or --from-modules-json, or
+// an empty string when a directory is scanned.
+func (o options) inputFile() string {
+	if o.fromState != "" {
+		return o.fromState
+	}
+	return o.fromModules
+}
+
+// declaration records where a module or provider was declared, with the
+// source and version exactly as written.
+type declaration struct {
+	Source  string
+	File    string
+	Line    int
+	Version string
+}
+
+// dependency is a unique module or provider address together with every place
+// it is declared. Version is the declared version with the lowest lower bound,
+// so that a dependency declared with several versions is compared by its
+// oldest one whatever order the files are scanned in.
+type dependency struct {
+	Source       string
+	Version      string
+	Declarations []declaration
+
+	// Lookup is the address versions are looked up with when a --rewrite
+	// rule applies to Source.
+	Lookup string
+}
+
+// lookupSource returns the address the dependency's versions are looked up
+// with.
+func (d *dependency) lookupSource() string {
+	if d.Lookup != "" {
+		return d.Lookup
+	}
+	return d.Source
+}
+
+// scanResult holds everything extracted from the .tf files under the scan root.
+type scanResult struct {
+	moduleMap   map[string]*dependency
+	providerMap map[string]*dependency
+	issues      []parseIssue
+	files       int
+
+	// walked counts every file visited by the walk, scanned or not.
+	walked int
+
+	// coreMap holds every distinct Terraform required_version constraint.
+	coreMap map[string]*dependency
+
+	// requirements lists every required_providers entry, and usedProviders
+	// and moduleCallDirs record per directory which provider local names are
+	// referenced by resources and whether any modules are called.
+	requirements   []providerRequirement
+	usedProviders  map[string]map[string]bool
+	moduleCallDirs map[string]bool
+
+	// moduleProviderRefs lists the entries of every module call's providers map.
+	moduleProviderRefs []moduleProviderRef
+
+	// providerBlocks lists every provider configuration block, by local name
+	// and legacy version argument, for addProviderBlocks.
+	providerBlocks []providerRequirement
+
+	// fileDirs maps every extracted file to the directories it was found in.
+	// A file symlinked into several directories is extracted only once.
+	fileDirs map[string][]string
+}
+
+// newScanResult returns an empty scanResult.
+func newScanResult() *scanResult {
+	return &scanResult{
+		moduleMap:      make(map[string]*dependency),
+		providerMap:    make(map[string]*dependency),
+		coreMap:        make(map[string]*dependency),
+		usedProviders:  make(map[string]map[string]bool),
+		moduleCallDirs: make(map[string]bool),
+		fileDirs:       make(map[string][]string),
+	}
+}
+
+// dirsOf returns the directories whose configuration includes file.
+func (r *scanResult) dirsOf(file string) []string {
+	if dirs := r.fileDirs[file]; len(dirs) > 0 {
+		return dirs
+	}
+	return []string{filepath.Dir(file)}
+}
+
+// sharedFiles returns the files that are included in more than one directory,
+// sorted by path.
+func (r *scanResult) sharedFiles() []sharedFile {
+	var shared []sharedFile
+	for file, dirs := range r.fileDirs {
+		if len(dirs) > 1 {
+			shared = append(shared, sharedFile{File: file, Dirs: dirs})
+		}
+	}
+	sort.Slice(shared, func(i, j int) bool {
+		return shared[i].File < shared[j].File
+	})
+	return shared
+}
+
+// sharedFile is a file included in several directories and the directories
+// that depend on it.
+type sharedFile struct {
+	File string
+	Dirs []string
+}
+
+// parseIssue describes a problem found while extracting declarations from a file.
+type parseIssue struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (i parseIssue) String() string {
+	return fmt.Sprintf("%s:%d: %s", i.File, i.Line, i.Message)
+}
+
+// addDeclaration records a declaration of the dependency at address in the
+// given map.
+func addDeclaration(deps map[string]*dependency, address string, decl declaration) {
+	dep, ok := deps[address]
+	if !ok {
+		dep = &dependency{Source: address}
+		deps[address] = dep
+	}
+	if dep.Version == "" || lowerConstraint(decl.Version, dep.Version) {
+		dep.Version = decl.Version
+	}
+	dep.Declarations = append(dep.Declarations, decl)
+}
+
+// sortedDependencies returns the dependencies in deps ordered by address, so
+// that lookups and reports come out in the same order on every run.
+func sortedDependencies(deps map[string]*dependency) []*dependency {
+	sorted := make([]*dependency, 0, len(deps))
+	for _, dep := range deps {
+		sorted = append(sorted, dep)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Source < sorted[j].Source
+	})
+	return sorted
+}
+
+// declaredSources returns the distinct sources the dependency was declared
+// with, in the order they were first seen.
+func (d *dependency) declaredSources() []string {
+	var sources []string
+	seen := make(map[string]bool)
+	for _, decl := range d.Declarations {
+		if !seen[decl.Source] {
+			seen[decl.Source] = true
+			sources = append(sources, decl.Source)
+		}
+	}
+	return sources
+}
+
+// ScanDir scans the .tf and .tf.json files below path and looks up the latest
+// version of every module and provider with DefaultClient.
+func ScanDir(path string) (*Report, error) {
+	return DefaultClient.ScanDir(context.Background(), path)
+}
+
+// ScanDir scans the .tf and .tf.json files below path and looks up the latest
+// version of every module and provider. The lookups stop when ctx is
+// cancelled, leaving the remaining dependencies out of the report.
+func (c *Client) ScanDir(ctx context.Context, path string) (*Report, error) {
+	result, err := scanDirectory(path, pathFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	canonicalizeProviders(result, c.defaultNamespace())
+	results := lookupLatestVersions(ctx, c, result, newProgress(0, true), nil)
+	doc := newReport(&report{RootPath: path, Files: result.files, Results: results})
+	return &doc, ctx.Err()
+}
+
+// runScan scans the root path and prints the current and latest versions of
+// every module and provider found. When ctx is cancelled, the lookups stop and
+// the results found so far are printed.
+func runScan(ctx context.Context, opts options) error {
+	start := time.Now()
+	if (opts.format == "text" || opts.format == "table") && !opts.quiet {
+		if input := opts.inputFile(); input != "" {
+			fmt.Println("Reading file:", input)
+		} else {
+			fmt.Println("Scanning directory:", opts.rootPath)
+		}
+		fmt.Println("")
+	}
+
+	var result *scanResult
+	var err error
+	switch {
+	case opts.fromState != "":
+		result, err = readState(opts.fromState)
+	case opts.fromModules != "":
+		result, err = readModulesJSON(opts.fromModules)
+	default:
+		result, err = scanDirectory(opts.rootPath, opts.filter)
+	}
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %s", err), 1)
+	}
+
+	if opts.parseOnly {
+		return reportParseIssues(result.issues)
+	}
+
+	stats := &scanStats{walked: result.walked, scanned: result.files, scan: time.Since(start)}
+
+	canonicalizeProviders(result, opts.namespace)
+	removeIgnored(result, opts.config)
+	resolveVars(result, opts.vars)
+	applyRewrites(result, opts.rewrites)
+
+	out := io.Writer(os.Stdout)
+	if opts.output != "" {
+		f, err := os.Create(opts.output)
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("Error: %s", err), 1)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if opts.offline {
+		return renderOffline(out, opts, result)
+	}
+
+	fetchStart := time.Now()
+	client := newScanClient(ctx, opts, stats)
+	if opts.respectCore {
+		if core := minCoreVersion(result); core != nil {
+			client.ProviderProtocols = coreProtocols(core)
+			logger.Debug("only considering providers compatible with the Terraform version", "terraform", core, "protocols", client.ProviderProtocols)
+		} else {
+			logger.Info("no required_version found, provider versions are not filtered by protocol")
+		}
+	}
+
+	// When streaming, each result is printed as soon as its lookup completes
+	// instead of being held until the whole report is rendered. JSON Lines
+	// output is always streamed. Comparing against a previous report and
+	// grouping need every result, so they are never streamed.
+	streamed := ((opts.stream && opts.format == "text") || opts.format == "jsonl") && opts.previous == nil && opts.groupBy == ""
+	var emit func(lookupResult)
+	var streamErr error
+	if streamed {
+		emit = func(r lookupResult) {
+			r.Pinned = opts.config.pinned(r.Dependency)
+			if r.Err == nil && !atLeastLevel(r.level(), opts.level) {
+				return
+			}
+			if opts.format == "jsonl" {
+				if err := renderJSONLine(out, r); err != nil && streamErr == nil {
+					streamErr = err
+				}
+			} else {
+				renderResult(out, r)
+			}
+		}
+	}
+
+	prog := newProgress(len(result.moduleMap)+len(result.providerMap), opts.quiet || streamed)
+	results := lookupLatestVersions(ctx, client, result, prog, emit)
+	applyPins(results, opts.config)
+	var groups []dirGroup
+	if opts.groupBy == "dir" {
+		groups = groupByDir(opts.rootPath, results, result, opts.level)
+	}
+
+	// Coverage is of every dependency found, whatever --level leaves out
+	var coverage *Coverage
+	if opts.coverage {
+		coverage = computeCoverage(results)
+	}
+	results = filterLevel(results, opts.level)
+
+	rep := &report{
+		RootPath:        opts.rootPath,
+		Files:           result.files,
+		Input:           opts.inputFile(),
+		Results:         results,
+		UnusedProviders: unusedProviders(result),
+		SharedFiles:     result.sharedFiles(),
+		Targets:         checkTargets(ctx, client, result, opts.config.Targets),
+		Streamed:        streamed,
+		GroupByDir:      opts.groupBy == "dir",
+		Groups:          groups,
+		Coverage:        coverage,
+	}
+	if opts.checkCore {
+		rep.Core = lookupCoreVersion(ctx, client, result)
+	}
+	if opts.moduleProviders {
+		rep.ModuleProviders = checkModuleProviderRefs(result)
+	}
+	if len(opts.approvedHosts) > 0 {
+		rep.HostViolations = checkApprovedHosts(result, opts.approvedHosts, client.registryHost())
+	}
+	if opts.plan {
+		rep.Plan = buildPlan(rep.Results)
+		rep.ShowPlan = true
+	}
+	stats.fetch = time.Since(fetchStart)
+
+	switch {
+	case opts.previous != nil && opts.format == "json":
+		err = writeJSON(out, compareReports(opts.compare, *opts.previous, newReport(rep)))
+		renderSummary(os.Stderr, rep)
+	case opts.previous != nil:
+		renderDelta(out, compareReports(opts.compare, *opts.previous, newReport(rep)))
+		renderSummary(out, rep)
+	case opts.format == "json":
+		err = renderJSON(out, rep)
+		renderSummary(os.Stderr, rep)
+	case opts.format == "sarif":
+		err = renderSARIF(out, rep)
+		renderSummary(os.Stderr, rep)
+	case opts.format == "html":
+		err = renderHTML(out, rep, start)
+		renderSummary(os.Stderr, rep)
+	case opts.format == "jsonl":
+		err = streamErr
+		for _, r := range rep.Core {
+			if err == nil {
+				err = renderJSONLine(out, r)
+			}
+		}
+		renderSummary(os.Stderr, rep)
+	case opts.format == "table":
+		renderTable(out, rep, opts.maxWidth)
+		renderSummary(out, rep)
+	default:
+		renderText(out, rep)
+		renderSummary(out, rep)
+	}
+	if opts.stats {
+		stats.total = time.Since(start)
+		renderStats(os.Stderr, stats)
+	}
+	if err != nil {
+		return err
+	}
+
+	if ctx.Err() != nil {
+		return cli.Exit("Interrupted, the report is incomplete", 130)
+	}
+
+	skipped := 0
+	for _, r := range append(rep.Results, rep.Core...) {
+		if errors.Is(r.Err, errBudgetExceeded) {
+			skipped++
+		}
+	}
+	if skipped > 0 {
+		return cli.Exit(fmt.Sprintf("Exceeded the budget of %d registry requests, %d lookup(s) were not made", opts.maxRequests, skipped), 1)
+	}
+
+	if failed := len(rep.failedLookups()); opts.failOnError && failed > 0 {
+		return cli.Exit(fmt.Sprintf("%d lookup(s) failed", failed), 1)
+	}
+
+	if opts.failOnUnpinned {
+		unpinned := 0
+		for _, r := range rep.Results {
+			if r.unpinned() {
+				unpinned++
+			}
+		}
+		if unpinned > 0 {
+			return cli.Exit(fmt.Sprintf("%d dependencies are unpinned", unpinned), 1)
+		}
+	}
+
+	// Every declaration is checked, as a dependency may be declared at
+	// several versions
+	if opts.failOnOutdated != "" {
+		outdated := 0
+		for _, r := range rep.Results {
+			if len(r.outdatedDeclarations(opts.failOnOutdated)) > 0 {
+				outdated++
+			}
+		}
+		switch {
+		case outdated == 0:
+		case opts.failOnOutdated == levelPatch:
+			return cli.Exit(fmt.Sprintf("%d dependencies are outdated", outdated), 1)
+		default:
+			return cli.Exit(fmt.Sprintf("%d dependencies have a %s or greater update available", outdated, opts.failOnOutdated), 1)
+		}
+	}
+
+	return nil
+}
+
+// newScanClient returns the registry client for a scan, which is the default
+// client with the settings from opts applied, counting its work in stats.
+func newScanClient(ctx context.Context, opts options, stats *scanStats) *Client {
+	client := *DefaultClient
+	client.Backoff = opts.backoff
+	client.IncludeDeprecated = opts.deprecated
+	client.GitHubToken = os.Getenv("GITHUB_TOKEN")
+	client.Logger = logger
+	client.AllowedHosts = opts.allowedHosts
+	client.DefaultNamespace = opts.namespace
+	client.cache = opts.cache
+	if client.cache == nil {
+		client.cache = newReleaseCache()
+	}
+	client.stats = stats
+	if opts.maxRequests > 0 {
+		client.budget = newRequestBudget(opts.maxRequests)
+	}
+	if opts.rate > 0 {
+		client.Limiter = rate.NewLimiter(rate.Limit(opts.rate), 1)
+	}
+	if opts.registryURL != "" {
+		client.BaseURL = opts.registryURL
+	}
+
+	// A registry without a discovery document is assumed to use the
+	// standard paths
+	if err := client.Discover(ctx); err != nil {
+		logger.Debug("using the standard registry paths", "registry", client.BaseURL, "err", err)
+	}
+	return &client
+}
+
+// dependencyReleases returns the releases and published versions of a module,
+// from its registry or GitHub, or of a provider. Each source is looked up
+// once per cache, however many times it is declared or checked.
+func (c *Client) dependencyReleases(ctx context.Context, kind, source string) ([]*semver.Version, []*semver.Version, error) {
+	if kind == kindProvider {
+		key := fmt.Sprintf("%s:%s:%v", kindProvider, source, c.ProviderProtocols)
+		return c.lookupReleases(key, func() ([]*semver.Version, []*semver.Version, error) {
+			return c.providerReleases(ctx, source)
+		})
+	}
+
+	return c.lookupReleases(kindModule+":"+source, func() ([]*semver.Version, []*semver.Version, error) {
+		if owner, repo, ok := githubRepo(source); ok {
+			return c.githubReleases(ctx, owner, repo)
+		}
+		return c.moduleReleases(ctx, source)
+	})
+}
+
+// lookupLatestVersions fetches the latest version of every module and then
+// every provider found in the scan, each in order of source, reporting each
+// completed lookup to prog and, if it is not nil, passing each result to emit
+// as soon as it is known. Once ctx is cancelled no further lookups are made,
+// and the lookup that was in flight is dropped.
+func lookupLatestVersions(ctx context.Context, client *Client, result *scanResult, prog *progress, emit func(lookupResult)) []lookupResult {
+	var results []lookupResult
+	defer prog.finish()
+
+	add := func(r lookupResult) {
+		if ctx.Err() != nil {
+			return
+		}
+		if r.Err != nil {
+			logger.Info("lookup failed", "kind", r.Kind, "source", r.Dependency.Source, "err", r.Err)
+		} else if r.Skipped {
+			logger.Info("lookup skipped, host not allowed", "kind", r.Kind, "source", r.Dependency.Source)
+		} else if r.Dynamic {
+			logger.Debug("lookup skipped, dynamic source", "kind", r.Kind, "source", r.Dependency.Source)
+		} else if r.NotChecked {
+			logger.Debug("lookup skipped, not in a registry or on GitHub", "kind", r.Kind, "source", r.Dependency.Source)
+		} else if r.NotFound {
+			logger.Debug("no versions found", "kind", r.Kind, "source", r.Dependency.Source)
+		} else {
+			logger.Debug("lookup complete", "kind", r.Kind, "source", r.Dependency.Source, "latest", r.Latest)
+		}
+		results = append(results, r)
+		if emit != nil {
+			emit(r)
+		}
+		prog.increment()
+	}
+
+	for _, dep := range sortedDependencies(result.moduleMap) {
+		if ctx.Err() != nil {
+			return results
+		}
+		switch sourceKind(dep.lookupSource()) {
+		case sourceDynamic:
+			add(lookupResult{Kind: kindModule, Dependency: dep, Dynamic: true})
+			continue
+		case sourceLocal:
+			add(lookupResult{Kind: kindModule, Dependency: dep, NotChecked: true})
+			continue
+		case sourceRemote:
+			// Only GitHub has an API to list a remote module's versions
+			if _, _, ok := githubRepo(dep.lookupSource()); !ok {
+				add(lookupResult{Kind: kindModule, Dependency: dep, NotChecked: true})
+				continue
+			}
+		}
+
+		releases, published, err := client.dependencyReleases(ctx, kindModule, dep.lookupSource())
+		add(newReleasesResult(kindModule, dep, releases, published, err))
+	}
+
+	for _, dep := range sortedDependencies(result.providerMap) {
+		if ctx.Err() != nil {
+			return results
+		}
+		releases, published, err := client.dependencyReleases(ctx, kindProvider, dep.lookupSource())
+		add(newReleasesResult(kindProvider, dep, releases, published, err))
+	}
+
+	return results
+}
+
+// scanDirectory walks rootPath and extracts the modules and providers declared
+// in every .tf file below it that filter allows.
+func scanDirectory(rootPath string, filter pathFilter) (*scanResult, error) {
+	result := newScanResult()
+
+	err := filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Skip directories starting with "."
+		if info.IsDir() && strings.HasPrefix(info.Name(), ".") && path != rootPath {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(rootPath, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && filter.excludesDir(rel) {
+			return filepath.SkipDir
+		}
+
+		if !info.IsDir() {
+			result.walked++
+		}
+
+		// Process only .tf and .tf.json files
+		isJSON := strings.HasSuffix(path, ".tf.json")
+		if info.IsDir() || (filepath.Ext(path) != ".tf" && !isJSON) || !filter.allows(rel) {
+			return nil
+		}
+
+		// A file symlinked into several directories, such as a central
+		// versions.tf, is extracted once and attributed to each directory.
+		file := path
+		if info.Mode()&os.ModeSymlink != 0 {
+			if file, err = filepath.EvalSymlinks(path); err != nil {
+				return err
+			}
+		}
+
+		if _, seen := result.fileDirs[file]; !seen {
+			extract := extractModules
+			if isJSON {
+				extract = extractJSON
+			}
+			if err := extract(file, result); err != nil {
+				return err
+			}
+			result.files++
+		}
+		result.fileDirs[file] = append(result.fileDirs[file], filepath.Dir(path))
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	addProviderBlocks(result)
+	return result, nil
+}
+
+// reportParseIssues prints every parse and source issue found during the scan
+// and fails if there were any.
+func reportParseIssues(issues []parseIssue) error {
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+
+	if len(issues) > 0 {
+		return cli.Exit(fmt.Sprintf("Found %d parse or source issue(s)", len(issues)), 1)
+	}
+
+	fmt.Println("No parse or source issues found")
+	return nil
+}
+
+// extractModules scans a Terraform file and extracts module sources and versions
+func extractModules(filePath string, result *scanResult) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	moduleRegex := regexp.MustCompile(`module\s+"([^"]+)"\s*{`)
+
+	// Regular expressions to extract source and version
+	sourceRegex := regexp.MustCompile(`\bsource\s*=\s*` + quotedString)
+	versionRegex := regexp.MustCompile(`\bversion\s*=\s*` + quotedString)
+
+	// Regular expressions to find provider requirements and the resources using them
+	requiredProvidersRegex := regexp.MustCompile(`^\s*required_providers\s*{`)
+	requirementRegex := regexp.MustCompile(`^\s*([A-Za-z0-9_-]+)\s*=\s*(.*)$`)
+	resourceRegex := regexp.MustCompile(`^\s*(?:resource|data)\s+"([A-Za-z0-9-]+)[_"]`)
+	providerArgRegex := regexp.MustCompile(`^\s*provider\s*=\s*([A-Za-z0-9_-]+)`)
+	legacyVersionRegex := regexp.MustCompile(`^` + quotedString)
+	requiredVersionRegex := regexp.MustCompile(`^\s*required_version\s*=\s*` + quotedString)
+	providerBlockRegex := regexp.MustCompile(`^\s*provider\s+` + quotedString + `\s*{`)
+
+	// Regular expressions to extract the providers passed to a module call
+	moduleProvidersRegex := regexp.MustCompile(`^\s*providers\s*=\s*{(.*)$`)
+	providerRefRegex := regexp.MustCompile(`([A-Za-z0-9_-]+(?:\.[A-Za-z0-9_-]+)?)\s*=\s*([A-Za-z0-9_-]+(?:\.[A-Za-z0-9_-]+)?)`)
+
+	dir := filepath.Dir(filePath)
+
+	addIssue := func(line int, format string, args ...interface{}) {
+		result.issues = append(result.issues, parseIssue{
+			File:    filePath,
+			Line:    line,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	lineNumber := 0
+	braceDepth := 0
+	nextLine := func() (string, bool) {
+		if !scanner.Scan() {
+			return "", false
+		}
+		lineNumber++
+		line := stripComment(scanner.Text())
+		braceDepth += countBraces(line)
+		return line, true
+	}
+
+	for line, ok := nextLine(); ok; line, ok = nextLine() {
+		if moduleMatch := moduleRegex.FindStringSubmatch(line); moduleMatch != nil {
+			source := ""
+			version := ""
+			moduleLine := lineNumber
+			blockDepth := braceDepth - countBraces(line)
+			result.moduleCallDirs[dir] = true
+
+			// The block ends when the depth drops back to where it was
+			// before the opening line, which may be on that same line as in
+			// module "x" { source = "..." }. Whatever follows the opening
+			// brace is read like any other line of the block. Only the
+			// block's own attributes are read, not those of nested blocks
+			// and objects such as a version inside an inputs map.
+			line = line[strings.Index(line, moduleMatch[0])+len(moduleMatch[0]):]
+			for {
+				lineDepth := braceDepth - countBraces(line)
+				if value, found := attributeAt(sourceRegex, line, lineDepth, blockDepth+1); found {
+					source = value
+				}
+				if value, found := attributeAt(versionRegex, line, lineDepth, blockDepth+1); found {
+					version = value
+				}
+				if providersMatch := moduleProvidersRegex.FindStringSubmatch(line); providersMatch != nil {
+					refLine := lineNumber
+					text := providersMatch[1]
+					for depth := countBraces(line); depth > 0 && ok; {
+						if line, ok = nextLine(); ok {
+							depth += countBraces(line)
+							text += "\n" + line
+						}
+					}
+
+					for _, refMatch := range providerRefRegex.FindAllStringSubmatch(text, -1) {
+						ref := moduleProviderRef{Module: moduleMatch[1], File: filePath, Line: refLine, Child: refMatch[1], Parent: refMatch[2]}
+						result.moduleProviderRefs = append(result.moduleProviderRefs, ref)
+						markProviderUsed(result, dir, ref.parentName())
+					}
+				}
+				if braceDepth <= blockDepth {
+					break
+				}
+				if line, ok = nextLine(); !ok {
+					break
+				}
+			}
+
+			if braceDepth > blockDepth {
+				addIssue(moduleLine, "module block is not terminated by a closing brace")
+			}
+
+			addModuleCall(result, source, version, filePath, moduleLine)
+		} else if requiredProvidersRegex.MatchString(line) {
+			blockDepth := braceDepth - 1
+			var req *providerRequirement
+
+			for line, ok = nextLine(); ok && braceDepth > blockDepth; line, ok = nextLine() {
+				if req == nil {
+					match := requirementRegex.FindStringSubmatch(line)
+					if match == nil {
+						continue
+					}
+					req = &providerRequirement{Name: match[1], File: filePath, Line: lineNumber}
+					if versionMatch := legacyVersionRegex.FindStringSubmatch(match[2]); versionMatch != nil {
+						// Legacy shorthand: name = "version constraint"
+						req.Version = quotedValue(versionMatch)
+					}
+				}
+
+				// The attributes of an entry are one level inside it
+				lineDepth := braceDepth - countBraces(line)
+				if value, found := attributeAt(sourceRegex, line, lineDepth, blockDepth+2); found {
+					req.Source = value
+				}
+				if value, found := attributeAt(versionRegex, line, lineDepth, blockDepth+2); found {
+					req.Version = value
+				}
+
+				if braceDepth == blockDepth+1 {
+					addRequirement(result, *req)
+					req = nil
+				}
+			}
+		} else if providerMatch := providerBlockRegex.FindStringSubmatch(line); providerMatch != nil {
+			block := providerRequirement{Name: quotedValue(providerMatch), File: filePath, Line: lineNumber}
+			blockDepth := braceDepth - countBraces(line)
+
+			// Like a module block, a provider block may open and close on
+			// the same line. Only its own version argument, the legacy way
+			// of constraining the provider, is read.
+			line = line[strings.Index(line, providerMatch[0])+len(providerMatch[0]):]
+			for {
+				lineDepth := braceDepth - countBraces(line)
+				if value, found := attributeAt(versionRegex, line, lineDepth, blockDepth+1); found {
+					block.Version = value
+				}
+				if braceDepth <= blockDepth {
+					break
+				}
+				if line, ok = nextLine(); !ok {
+					break
+				}
+			}
+
+			if braceDepth > blockDepth {
+				addIssue(block.Line, "provider block is not terminated by a closing brace")
+			}
+			result.providerBlocks = append(result.providerBlocks, block)
+		} else if coreMatch := requiredVersionRegex.FindStringSubmatch(line); coreMatch != nil {
+			constraint := quotedValue(coreMatch)
+			addDeclaration(result.coreMap, constraint, declaration{Source: "required_version", File: filePath, Line: lineNumber, Version: constraint})
+		} else if resourceMatch := resourceRegex.FindStringSubmatch(line); resourceMatch != nil {
+			markProviderUsed(result, dir, resourceMatch[1])
+		} else if providerArgMatch := providerArgRegex.FindStringSubmatch(line); providerArgMatch != nil {
+			markProviderUsed(result, dir, providerArgMatch[1])
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if braceDepth != 0 {
+		addIssue(lineNumber, "unbalanced braces (%+d at end of file)", braceDepth)
+	}
+
+	return nil
+}
+
+// addModuleCall records a module block with the given source and version
+// attributes, reporting a missing or invalid source as an issue.
+func addModuleCall(result *scanResult, source, version, filePath string, line int) {
+	if source == "" {
+		result.issues = append(result.issues, parseIssue{File: filePath, Line: line, Message: "module block has no source"})
+		return
+	}
+	if err := validateModuleSource(source); err != nil {
+		result.issues = append(result.issues, parseIssue{File: filePath, Line: line, Message: err.Error()})
+	}
+
+	// A module taken from GitHub is versioned by the ref it is pinned to
+	if _, _, ok := githubRepo(source); ok && version == "" {
+		version = gitRef(source)
+	}
+	addDeclaration(result.moduleMap, normalizeModuleAddress(moduleAddress(source)), declaration{Source: source, File: filePath, Line: line, Version: version})
+}
+
+// quotedString matches a string in double or single quotes, capturing its
+// contents in one of two groups so that the closing quote must match the
+// opening one. quotedValue returns whichever group matched.
+const quotedString = `(?:"([^"]+)"|'([^']+)')`
+
+// quotedValue returns the contents of the string matched by quotedString in
+// match, whose last two groups are those of quotedString.
+func quotedValue(match []string) string {
+	if value := match[len(match)-2]; value != "" {
+		return value
+	}
+	return match[len(match)-1]
+}
+
+// attributeAt returns the value of the first attribute matched by re in line
+// that is at brace depth depth, given the depth at the start of line.
+// Attributes of nested blocks and objects on the line are skipped.
+func attributeAt(re *regexp.Regexp, line string, lineDepth, depth int) (string, bool) {
+	for _, loc := range re.FindAllStringSubmatchIndex(line, -1) {
+		if lineDepth+countBraces(line[:loc[0]]) != depth {
+			continue
+		}
+		match := make([]string, len(loc)/2)
+		for i := range match {
+			if loc[2*i] >= 0 {
+				match[i] = line[loc[2*i]:loc[2*i+1]]
+			}
+		}
+		return quotedValue(match), true
+	}
+	return "", false
+}
+
+// stripComment returns line without a trailing # or // comment, so that
+// commented-out attributes and blocks are not extracted. Comment markers
+// inside quoted strings, as in URLs, are kept.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if quote != 0 {
+			switch c {
+			case '\\':
+				i++
+			case quote:
+				quote = 0
+			}
+			continue
+		}
+
+		switch {
+		case c == '"', c == '\'':
+			quote = c
+		case c == '#', c == '/' && i+1 < len(line) && line[i+1] == '/':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// countBraces returns the number of opening minus closing braces on a line,
+// ignoring braces inside quoted strings and comments.
+func countBraces(line string) int {
+	depth := 0
+	inString := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if inString {
+			switch c {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch {
+		case c == '"':
+			inString = true
+		case c == '#', c == '/' && i+1 < len(line) && line[i+1] == '/':
+			return depth
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		}
+	}
+	return depth
+}
+
+func pathExists(path string) bool {
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false
+	}
+	return err == nil
+}
+
+// NewApp returns the tfridge command line application.
+func NewApp() *cli.App {
+	// The configuration file is found once, before the flags are applied,
+	// and read again by the action for the settings that are not flags
+	var configPath string
+
+	return &cli.App{
+		Name:      "TFridge",
+		Usage:     "Scan a specified directory for Terraform module and provider updates",
+		Version:   appVersion,
+		ArgsUsage: "<path>",
+
+		Flags: []cli.Flag{
+			altsrc.NewBoolFlag(&cli.BoolFlag{
+				Name:  "parse-only",
+				Usage: "Only parse the .tf files and validate sources, without contacting the registry",
+			}),
+			altsrc.NewStringFlag(&cli.StringFlag{
+				Name:  "format",
+				Usage: "Output format: text, table, json, jsonl, sarif or html",
+				Value: "text",
+			}),
+			altsrc.NewStringFlag(&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   "Write the report to this file instead of stdout",
+			}),
+			&cli.StringFlag{
+				Name:  "from-state",
+				Usage: "Check the modules and providers in the output of terraform show -json for a state or plan, instead of scanning a directory",
+			},
+			&cli.StringFlag{
+				Name:  "from-modules-json",
+				Usage: "Check the module versions installed by terraform init, as listed in .terraform/modules/modules.json, instead of scanning a directory",
+			},
+			&cli.StringFlag{
+				Name:  "compare",
+				Usage: "Only show what changed since a previous report written by --format json (text and json formats only)",
+			},
+			altsrc.NewStringFlag(&cli.StringFlag{
+				Name:  "group-by",
+				Usage: "Group the results by the directory each declaration is in, with dir (text, table, json and html formats only)",
+			}),
+			altsrc.NewIntFlag(&cli.IntFlag{
+				Name:  "max-width",
+				Value: defaultMaxWidth,
+				Usage: "Truncate sources longer than this many characters in --format table, or 0 to never truncate",
+			}),
+			altsrc.NewBoolFlag(&cli.BoolFlag{
+				Name:  "check-core",
+				Usage: "Compare the Terraform required_version against the latest Terraform release",
+			}),
+			altsrc.NewBoolFlag(&cli.BoolFlag{
+				Name:  "module-providers",
+				Usage: "Report the providers passed to module calls and whether they are declared in required_providers",
+			}),
+			altsrc.NewBoolFlag(&cli.BoolFlag{
+				Name:  "coverage",
+				Usage: "Summarize how many dependencies could be resolved, and why the others could not",
+			}),
+			altsrc.NewBoolFlag(&cli.BoolFlag{
+				Name:  "plan",
+				Usage: "Show a remediation plan ordering the outdated dependencies from patch to major upgrades",
+			}),
+			altsrc.NewBoolFlag(&cli.BoolFlag{
+				Name:  "fail-on-error",
+				Usage: "Exit with a non-zero status if any registry lookup failed",
+			}),
+			&levelThresholdFlag{&cli.GenericFlag{
+				Name:  "fail-on-outdated",
+				Value: &levelThreshold{},
+				Usage: "Exit with a non-zero status if any dependency is outdated, or with =LEVEL only for updates of at least major, minor or patch",
+			}},
+			altsrc.NewBoolFlag(&cli.BoolFlag{
+				Name:  "fail-on-unpinned",
+				Usage: "Exit with a non-zero status if any registry module or provider has no version constraint",
+			}),
+			altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+				Name:  "approved-hosts",
+				Usage: "Report modules whose source is fetched from a host not in this comma-separated list",
+			}),
+			altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+				Name:  "allowed-hosts",
+				Usage: "Only look up modules and providers whose source resolves to one of these comma-separated hosts (default: any)",
+			}),
+			altsrc.NewBoolFlag(&cli.BoolFlag{
+				Name:  "stream",
+				Usage: "Print each result as soon as its lookup completes instead of after the whole scan (text format only)",
+			}),
+			altsrc.NewStringFlag(&cli.StringFlag{
+				Name:  "level",
+				Usage: "Only report updates of at least this level: major, minor or patch",
+			}),
+			altsrc.NewStringFlag(&cli.StringFlag{
+				Name:  "registry",
+				Value: "terraform",
+				Usage: "Public registry to look up versions in: terraform or opentofu",
+			}),
+			altsrc.NewBoolFlag(&cli.BoolFlag{
+				Name:  "respect-core-constraint",
+				Usage: "Only consider provider versions whose plugin protocol is supported by the oldest Terraform version required_version allows",
+			}),
+			altsrc.NewBoolFlag(&cli.BoolFlag{
+				Name:  "include-deprecated",
+				Usage: "Consider versions the registry has marked as deprecated when looking up the latest version",
+			}),
+			altsrc.NewBoolFlag(&cli.BoolFlag{
+				Name:  "no-color",
+				Usage: "Disable colored output, which is also disabled when NO_COLOR is set or stdout is not a terminal",
+			}),
+			altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+				Name:  "include",
+				Usage: "Only scan files matching this glob, relative to the scanned directory (repeatable, ** matches any directories)",
+			}),
+			altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+				Name:  "exclude",
+				Usage: "Skip files matching this glob, relative to the scanned directory (repeatable)",
+			}),
+			altsrc.NewStringFlag(&cli.StringFlag{
+				Name:  "log-level",
+				Value: "warn",
+				Usage: "Write diagnostics of at least this level to stderr: debug, info, warn or error",
+			}),
+			altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+				Name:  "var",
+				Usage: "Set a variable used in interpolated module sources such as \"${var.registry}/vpc/aws\", as name=value (repeatable)",
+			}),
+			altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+				Name:  "rewrite",
+				Usage: "Look up sources starting with a prefix under another one, as from=to, e.g. for modules pulled from a mirror (repeatable)",
+			}),
+			altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+				Name:  "target",
+				Usage: "Compare a source against a planned version instead of only the latest, as source=version (repeatable)",
+			}),
+			altsrc.NewFloat64Flag(&cli.Float64Flag{
+				Name:  "rate",
+				Value: defaultRate,
+				Usage: "Maximum number of registry requests per second, or 0 for no limit",
+			}),
+			altsrc.NewBoolFlag(&cli.BoolFlag{
+				Name:  "watch",
+				Usage: "Scan again whenever a .tf or .tf.json file changes, reusing the versions already looked up, until interrupted",
+			}),
+			altsrc.NewIntFlag(&cli.IntFlag{
+				Name:  "max-requests",
+				Usage: "Fail once this many registry requests, including retries, have been made, or 0 for no limit",
+			}),
+			altsrc.NewStringFlag(&cli.StringFlag{
+				Name:  "namespace-default",
+				Value: defaultNamespace,
+				Usage: "Namespace of the providers whose source is a bare name, such as random",
+			}),
+			altsrc.NewBoolFlag(&cli.BoolFlag{
+				Name:  "offline",
+				Usage: "List every module and provider with its current version and declarations, without contacting any registry (text, json and jsonl formats only)",
+			}),
+			altsrc.NewBoolFlag(&cli.BoolFlag{
+				Name:  "stats",
+				Usage: "Print the files walked, requests made, cache use, bytes downloaded and time spent to stderr at the end",
+			}),
+			altsrc.NewStringFlag(&cli.StringFlag{
+				Name:  "backoff",
+				Usage: "Comma-separated retry intervals for failed registry requests, e.g. 1s,3s,10s (default: exponential, 3 retries)",
+			}),
+			&cli.StringFlag{
+				Name:  "config",
+				Usage: "Path to a configuration file (default: " + configFileName + " in the scanned directory or the home directory)",
+			},
+			altsrc.NewBoolFlag(&cli.BoolFlag{
+				Name:  "no-self-update-check",
+				Usage: "Do not check GitHub for a newer tfridge release, which is otherwise done at most once a day",
+			}),
+			altsrc.NewBoolFlag(&cli.BoolFlag{
+				Name:    "quiet",
+				Aliases: []string{"q"},
+				Usage:   "Suppress the progress indicator and informational messages",
+			}),
+		},
+
+		// Flags not given on the command line default to the values in the
+		// configuration file.
+		Before: func(c *cli.Context) error {
+			configPath = commandConfig(c)
+			if configPath == "" {
+				return nil
+			}
+
+			source, err := altsrc.NewYamlSourceFromFile(configPath)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("Error loading config: %s", err), 1)
+			}
+			return altsrc.ApplyInputSourceValues(c, source, c.App.Flags)
+		},
+
+		Commands: []*cli.Command{
+			{
+				Name:      "merge",
+				Usage:     "Merge JSON reports from several repositories into one aggregated report",
+				ArgsUsage: "<report.json>...",
+				Action: func(c *cli.Context) error {
+					if c.NArg() < 1 {
+						return cli.Exit("Please specify the JSON reports you want to merge", 1)
+					}
+					if err := runMerge(os.Stdout, c.Args().Slice()); err != nil {
+						return cli.Exit(fmt.Sprintf("Error: %s", err), 1)
+					}
+					return nil
+				},
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			opts := options{
+				rootPath:        c.Args().Get(0),
+				fromState:       c.String("from-state"),
+				fromModules:     c.String("from-modules-json"),
+				parseOnly:       c.Bool("parse-only"),
+				format:          c.String("format"),
+				quiet:           c.Bool("quiet"),
+				checkCore:       c.Bool("check-core"),
+				moduleProviders: c.Bool("module-providers"),
+				coverage:        c.Bool("coverage"),
+				plan:            c.Bool("plan"),
+				failOnError:     c.Bool("fail-on-error"),
+				failOnUnpinned:  c.Bool("fail-on-unpinned"),
+				failOnOutdated:  c.Generic("fail-on-outdated").(*levelThreshold).Level,
+				approvedHosts:   c.StringSlice("approved-hosts"),
+				stream:          c.Bool("stream"),
+				level:           c.String("level"),
+				deprecated:      c.Bool("include-deprecated"),
+				rate:            c.Float64("rate"),
+				allowedHosts:    c.StringSlice("allowed-hosts"),
+				output:          c.String("output"),
+				watch:           c.Bool("watch"),
+				groupBy:         c.String("group-by"),
+				respectCore:     c.Bool("respect-core-constraint"),
+				maxRequests:     c.Int("max-requests"),
+				stats:           c.Bool("stats"),
+				namespace:       c.String("namespace-default"),
+				offline:         c.Bool("offline"),
+				maxWidth:        c.Int("max-width"),
+				compare:         c.String("compare"),
+				filter: pathFilter{
+					Include: c.StringSlice("include"),
+					Exclude: c.StringSlice("exclude"),
+				},
+			}
+
+			// A state or modules.json file is read instead of scanning a
+			// directory, and the configuration file is looked for next to it
+			if input := opts.inputFile(); input != "" {
+				if opts.fromState != "" && opts.fromModules != "" {
+					return cli.Exit("Error: --from-state and --from-modules-json cannot be combined", 1)
+				}
+				if c.NArg() > 0 {
+					return cli.Exit("Error: a path cannot be scanned together with --from-state or --from-modules-json", 1)
+				}
+				if opts.watch {
+					return cli.Exit("Error: --watch cannot be combined with --from-state or --from-modules-json", 1)
+				}
+				if !pathExists(input) {
+					return cli.Exit(fmt.Sprintf("Path '%s' does not exist.", input), 1)
+				}
+				opts.rootPath = filepath.Dir(input)
+			} else if c.NArg() < 1 {
+				return cli.Exit("Please specify a path to the directory you want to scan", 1)
+			}
+
+			switch opts.format {
+			case "text", "table", "json", "jsonl", "sarif", "html":
+			default:
+				return cli.Exit(fmt.Sprintf("Unknown format '%s'.", opts.format), 1)
+			}
+
+			switch opts.groupBy {
+			case "":
+			case "dir":
+				if opts.format != "text" && opts.format != "table" && opts.format != "json" && opts.format != "html" {
+					return cli.Exit("Error: --group-by only supports the text, table, json and html formats", 1)
+				}
+			default:
+				return cli.Exit(fmt.Sprintf("Unknown --group-by '%s'.", opts.groupBy), 1)
+			}
+
+			level, err := parseLogLevel(c.String("log-level"))
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("Error: %s", err), 1)
+			}
+			logger = newLogger(os.Stderr, level)
+
+			// A report written to a file is not read in a terminal
+			if c.Bool("no-color") || opts.output != "" {
+				color.NoColor = true
+			}
+
+			if err := opts.filter.validate(); err != nil {
+				return cli.Exit(fmt.Sprintf("Error: %s", err), 1)
+			}
+
+			if err := validateLevel(opts.level); err != nil {
+				return cli.Exit(fmt.Sprintf("Error: %s", err), 1)
+			}
+
+			if !registryNameRegex.MatchString(opts.namespace) {
+				return cli.Exit(fmt.Sprintf("Error: namespace %q is not a valid registry namespace", opts.namespace), 1)
+			}
+
+			if !pathExists(opts.rootPath) {
+				errMsg := fmt.Sprintf("Path '%s' does not exist.", opts.rootPath)
+				return cli.Exit(errMsg, 1)
+			}
+
+			if c.IsSet("registry") {
+				url, ok := registryPresets[c.String("registry")]
+				if !ok {
+					return cli.Exit(fmt.Sprintf("Unknown registry '%s'.", c.String("registry")), 1)
+				}
+				opts.registryURL = url
+			}
+
+			if c.IsSet("backoff") {
+				backoff, err := parseBackoff(c.String("backoff"))
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("Error: %s", err), 1)
+				}
+				opts.backoff = backoff
+			}
+
+			vars, err := parseVars(c.StringSlice("var"))
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("Error: %s", err), 1)
+			}
+			opts.vars = vars
+
+			rewrites, err := parseRewrites(c.StringSlice("rewrite"))
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("Error: %s", err), 1)
+			}
+			opts.rewrites = rewrites
+
+			if opts.offline && opts.format != "text" && opts.format != "json" && opts.format != "jsonl" {
+				return cli.Exit("Error: --offline only supports the text, json and jsonl formats", 1)
+			}
+
+			if opts.compare != "" {
+				if opts.format != "text" && opts.format != "json" {
+					return cli.Exit("Error: --compare only supports the text and json formats", 1)
+				}
+				previous, err := readReport(opts.compare)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("Error reading previous report: %s", err), 1)
+				}
+				opts.previous = previous
+			}
+
+			cfg, err := loadConfig(configPath)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("Error loading config: %s", err), 1)
+			}
+			opts.config = cfg
+
+			targets, err := parseTargets(c.StringSlice("target"))
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("Error: %s", err), 1)
+			}
+			if len(targets) > 0 && cfg.Targets == nil {
+				cfg.Targets = make(map[string]string)
+			}
+			for source, target := range targets {
+				cfg.Targets[source] = target
+			}
+
+			// The parse-only and offline modes promise not to use the network
+			var notice <-chan string
+			if !c.Bool("no-self-update-check") && !opts.parseOnly && !opts.offline {
+				notice = startSelfUpdateCheck(c.Context, opts)
+			}
+
+			if opts.watch {
+				err = runWatch(c.Context, opts)
+			} else {
+				err = runScan(c.Context, opts)
+			}
+			if notice != nil {
+				printSelfUpdateNotice(os.Stderr, notice)
+			}
+			return err
+		},
+	}
+}
@@ -0,0 +1,334 @@
+package tfridge
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v2"
+)
+
+// fakeRegistry serves fixed responses by path, standing in for the registry,
+// the releases API and GitHub, and records every request it receives.
+type fakeRegistry struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []string
+}
+
+// newFakeRegistry starts a fakeRegistry serving routes, which map a request
+// path to a JSON response body. Any other path is not found.
+func newFakeRegistry(t *testing.T, routes map[string]string) *fakeRegistry {
+	t.Helper()
+	f := &fakeRegistry{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.requests = append(f.requests, r.URL.Path)
+		f.mu.Unlock()
+
+		body, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// requestCount returns the number of requests received so far.
+func (f *fakeRegistry) requestCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.requests)
+}
+
+// client returns a Client for the fake registry that does not retry.
+func (f *fakeRegistry) client() *Client {
+	c := NewClient(f.URL)
+	c.ReleasesURL = f.URL
+	c.GitHubURL = f.URL
+	c.HTTPClient = f.Client()
+	c.Backoff = []time.Duration{}
+	c.Logger = newLogger(io.Discard, slog.LevelWarn)
+	return c
+}
+
+// useAsDefault makes DefaultClient, which the command line uses, a client for
+// the fake registry until the end of the test.
+func (f *fakeRegistry) useAsDefault(t *testing.T) {
+	old := DefaultClient
+	DefaultClient = f.client()
+	t.Cleanup(func() { DefaultClient = old })
+}
+
+// writeFiles creates files, given by path relative to a new temporary
+// directory, and returns that directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// runApp runs the command line with args, without the self-update check, a
+// rate limit or a configuration file from the home directory, and returns the
+// error it exits with.
+func runApp(t *testing.T, args ...string) error {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	app := NewApp()
+	app.ExitErrHandler = func(*cli.Context, error) {}
+	return app.Run(append([]string{"tfridge", "--no-self-update-check", "--quiet", "--rate", "0"}, args...))
+}
+
+// exitCode returns the status the command line exits with for err.
+func exitCode(err error) int {
+	var exitErr cli.ExitCoder
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+func TestParseOnlyFailsWithoutRequests(t *testing.T) {
+	registry := newFakeRegistry(t, nil)
+	registry.useAsDefault(t)
+
+	root := writeFiles(t, map[string]string{
+		"main.tf": `
+module "vpc" {
+  source  = "terraform-aws-modules/vpc"
+  version = "5.0.0"
+}
+
+module "broken" {
+  source = "./modules/broken"
+`,
+	})
+
+	if code := exitCode(runApp(t, "--parse-only", root)); code == 0 {
+		t.Errorf("--parse-only exited with status 0 for a malformed file")
+	}
+	if n := registry.requestCount(); n != 0 {
+		t.Errorf("--parse-only made %d HTTP requests, want none", n)
+	}
+}
+
+func TestParseOnlyPassesWellFormedFiles(t *testing.T) {
+	registry := newFakeRegistry(t, nil)
+	registry.useAsDefault(t)
+
+	root := writeFiles(t, map[string]string{
+		"main.tf": `
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.0.0"
+}
+`,
+	})
+
+	if err := runApp(t, "--parse-only", root); err != nil {
+		t.Errorf("--parse-only failed for a well-formed file: %v", err)
+	}
+	if n := registry.requestCount(); n != 0 {
+		t.Errorf("--parse-only made %d HTTP requests, want none", n)
+	}
+}
+
+func TestAddDeclarationKeepsOldestVersion(t *testing.T) {
+	orders := [][]string{
+		{"4.0.0", "", "~> 5.2", "latest"},
+		{"latest", "~> 5.2", "", "4.0.0"},
+	}
+	for _, versions := range orders {
+		deps := make(map[string]*dependency)
+		for i, version := range versions {
+			addDeclaration(deps, "terraform-aws-modules/vpc/aws", declaration{Source: "terraform-aws-modules/vpc/aws", File: "main.tf", Line: i + 1, Version: version})
+		}
+		if got := deps["terraform-aws-modules/vpc/aws"].Version; got != "4.0.0" {
+			t.Errorf("declared in order %q: got version %q, want 4.0.0", versions, got)
+		}
+	}
+}
+
+func TestOutdatedIgnoresScanOrder(t *testing.T) {
+	registry := newFakeRegistry(t, map[string]string{
+		"/v1/modules/terraform-aws-modules/vpc/aws/versions": `{"modules":[{"versions":[{"version":"4.0.0"},{"version":"5.2.0"}]}]}`,
+	})
+	registry.useAsDefault(t)
+
+	root := writeFiles(t, map[string]string{
+		"a/main.tf": `
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "4.0.0"
+}
+`,
+		"b/main.tf": `
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.2.0"
+}
+`,
+	})
+	output := filepath.Join(t.TempDir(), "report.json")
+	if err := runApp(t, "--format", "json", "-o", output, root); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := readReport(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Modules) != 1 {
+		t.Fatalf("got %d modules, want 1", len(doc.Modules))
+	}
+	vpc := doc.Modules[0]
+	if vpc.Current != "4.0.0" || !vpc.Outdated || vpc.Level != levelMajor {
+		t.Errorf("got current %s, outdated %v, level %q; want 4.0.0, outdated, major", vpc.Current, vpc.Outdated, vpc.Level)
+	}
+	if code := exitCode(runApp(t, "--fail-on-outdated", "-o", filepath.Join(t.TempDir(), "report.txt"), root)); code != 1 {
+		t.Errorf("--fail-on-outdated exited with status %d, want 1", code)
+	}
+}
+
+func TestOutdatedDeclarations(t *testing.T) {
+	r := lookupResult{
+		Kind: kindModule,
+		Dependency: &dependency{Source: "terraform-aws-modules/vpc/aws", Version: "5.1.0", Declarations: []declaration{
+			{Source: "terraform-aws-modules/vpc/aws", File: "a/main.tf", Line: 2, Version: "5.1.0"},
+			{Source: "terraform-aws-modules/vpc/aws", File: "b/main.tf", Line: 2, Version: "4.0.0"},
+			{Source: "terraform-aws-modules/vpc/aws", File: "c/main.tf", Line: 2, Version: "5.2.0"},
+		}},
+		Latest: "5.2.0",
+	}
+
+	tests := []struct {
+		min  string
+		want []string
+	}{
+		{"", []string{"a/main.tf", "b/main.tf"}},
+		{levelMinor, []string{"a/main.tf", "b/main.tf"}},
+		{levelMajor, []string{"b/main.tf"}},
+	}
+	for _, tt := range tests {
+		var files []string
+		for _, decl := range r.outdatedDeclarations(tt.min) {
+			files = append(files, decl.File)
+		}
+		if !reflect.DeepEqual(files, tt.want) {
+			t.Errorf("at least %q: got %v, want %v", tt.min, files, tt.want)
+		}
+	}
+}
+
+func TestOutputFileIsNotColored(t *testing.T) {
+	registry := newFakeRegistry(t, map[string]string{
+		"/v1/modules/terraform-aws-modules/vpc/aws/versions": `{"modules":[{"versions":[{"version":"5.0.0"},{"version":"6.0.0"}]}]}`,
+	})
+	registry.useAsDefault(t)
+	noColor := color.NoColor
+	color.NoColor = false
+	t.Cleanup(func() { color.NoColor = noColor })
+
+	root := writeFiles(t, map[string]string{
+		"main.tf": `
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.0.0"
+}
+`,
+	})
+	output := filepath.Join(t.TempDir(), "report.txt")
+	if err := runApp(t, "-o", output, root); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(report), "Latest version: 6.0.0 (1 release behind)\n") || strings.Contains(string(report), "\x1b[") {
+		t.Errorf("got report with --output:\n%q\nwant the latest version without color codes", report)
+	}
+}
+
+func TestModuleAttributesOfNestedObjectsAreIgnored(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"main.tf": `
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.0.0"
+  settings = {
+    version = "9.9.9"
+  }
+}
+
+module "eks" {
+  inputs = { source = "acme/other/aws", version = "9.9.9" }
+  source = "terraform-aws-modules/eks/aws"
+}
+
+module "iam" { source = "terraform-aws-modules/iam/aws" }
+`,
+	})
+	result, err := scanDirectory(root, pathFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]string)
+	for source, dep := range result.moduleMap {
+		got[source] = dep.Version
+	}
+	want := map[string]string{
+		"terraform-aws-modules/vpc/aws": "5.0.0",
+		"terraform-aws-modules/eks/aws": "",
+		"terraform-aws-modules/iam/aws": "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got modules %v, want %v", got, want)
+	}
+	if len(result.issues) != 0 {
+		t.Errorf("got issues %v, want none", result.issues)
+	}
+}
+
+func TestScanFailureExitsWithError(t *testing.T) {
+	newFakeRegistry(t, nil).useAsDefault(t)
+	root := writeFiles(t, map[string]string{"main.tf": ""})
+	if err := os.Symlink(filepath.Join(root, "missing.tf"), filepath.Join(root, "broken.tf")); err != nil {
+		t.Fatal(err)
+	}
+
+	err := runApp(t, "-o", filepath.Join(t.TempDir(), "report.txt"), root)
+	if code := exitCode(err); code != 1 || !strings.Contains(err.Error(), "missing.tf") {
+		t.Errorf("got %v with status %d, want the scan error with status 1", err, code)
+	}
+}
@@ -0,0 +1,53 @@
+package tfridge
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// varRefRegex matches a ${var.name} interpolation in a module source.
+var varRefRegex = regexp.MustCompile(`\$\{\s*var\.([A-Za-z0-9_-]+)\s*\}`)
+
+// parseVars parses --var values of the form name=value.
+func parseVars(values []string) (map[string]string, error) {
+	vars := make(map[string]string)
+	for _, value := range values {
+		name, val, ok := strings.Cut(value, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("variable %q is not of the form name=value", value)
+		}
+		vars[name] = val
+	}
+	return vars, nil
+}
+
+// interpolate replaces every ${var.name} in source with its value from vars.
+// It reports false if source still contains an interpolation afterwards,
+// because it refers to a variable without a value or to anything else.
+func interpolate(source string, vars map[string]string) (string, bool) {
+	resolved := varRefRegex.ReplaceAllStringFunc(source, func(ref string) string {
+		if value, ok := vars[varRefRegex.FindStringSubmatch(ref)[1]]; ok {
+			return value
+		}
+		return ref
+	})
+	return resolved, !strings.Contains(resolved, "${")
+}
+
+// resolveVars sets the lookup address of every module whose source is
+// interpolated from variables that all have a value in vars.
+func resolveVars(result *scanResult, vars map[string]string) {
+	if len(vars) == 0 {
+		return
+	}
+	for _, dep := range result.moduleMap {
+		if sourceKind(dep.Source) != sourceDynamic {
+			continue
+		}
+		if lookup, ok := interpolate(dep.Source, vars); ok {
+			logger.Debug("resolved dynamic source", "source", dep.Source, "lookup", lookup)
+			dep.Lookup = lookup
+		}
+	}
+}
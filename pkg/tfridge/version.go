@@ -0,0 +1,214 @@
+package tfridge
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// lowerBound returns the lowest version allowed by a version constraint such
+// as "5.1.0", "~> 5.1" or ">= 4.0, < 6.0". It returns nil when the constraint
+// has no lower bound or cannot be parsed.
+func lowerBound(constraint string) *semver.Version {
+	var bound *semver.Version
+	for _, part := range strings.Split(constraint, ",") {
+		part = strings.TrimSpace(part)
+		for _, op := range []string{"~>", ">=", "=", ">", "^", "~"} {
+			if strings.HasPrefix(part, op) {
+				part = strings.TrimSpace(strings.TrimPrefix(part, op))
+				break
+			}
+		}
+
+		if strings.HasPrefix(part, "<") || strings.HasPrefix(part, "!") {
+			continue
+		}
+
+		v, err := semver.NewVersion(part)
+		if err != nil {
+			continue
+		}
+		if bound == nil || v.GreaterThan(bound) {
+			bound = v
+		}
+	}
+	return bound
+}
+
+// lowerConstraint reports whether constraint a allows an older version than
+// constraint b, or whether only a has a lower bound.
+func lowerConstraint(a, b string) bool {
+	boundA, boundB := lowerBound(a), lowerBound(b)
+	if boundA == nil {
+		return false
+	}
+	return boundB == nil || boundA.LessThan(boundB)
+}
+
+// exactVersion returns the version required by a constraint that allows a
+// single version, such as "4.0.0" or "= 4.0.0", or nil for any other
+// constraint.
+func exactVersion(constraint string) *semver.Version {
+	v, err := semver.NewVersion(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(constraint), "=")))
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+// versionDistance returns how far apart two versions are, as a version whose
+// major, minor and patch numbers are the differences between those of a and
+// b. Comparing distances prefers a version in the same major and minor
+// release.
+func versionDistance(a, b *semver.Version) *semver.Version {
+	diff := func(x, y uint64) uint64 {
+		if x > y {
+			return x - y
+		}
+		return y - x
+	}
+	return semver.New(diff(a.Major(), b.Major()), diff(a.Minor(), b.Minor()), diff(a.Patch(), b.Patch()), "", "")
+}
+
+// upperBound returns the part of a comma-separated version constraint that
+// rules out latest, such as "< 6.0" for ">= 4.0, < 6.0" and latest 6.1.0, or
+// an empty string if no part does. A "~>" part is read the Terraform way,
+// where "~> 5.1" allows anything below 6.0 and "~> 5.1.0" anything below 5.2.
+func upperBound(constraint, latest string) string {
+	latestVersion, err := semver.NewVersion(latest)
+	if err != nil {
+		return ""
+	}
+
+	for _, part := range strings.Split(constraint, ",") {
+		part = strings.TrimSpace(part)
+		switch {
+		case strings.HasPrefix(part, "<"):
+			c, err := semver.NewConstraint(part)
+			if err == nil && !c.Check(latestVersion) {
+				return part
+			}
+		case strings.HasPrefix(part, "~>"):
+			if limit := pessimisticLimit(strings.TrimSpace(strings.TrimPrefix(part, "~>"))); limit != nil && !latestVersion.LessThan(limit) {
+				return part
+			}
+		}
+	}
+	return ""
+}
+
+// pessimisticLimit returns the first version excluded by the "~>" operator
+// applied to version, which is found by incrementing its second to last
+// segment. It returns nil if version cannot be parsed.
+func pessimisticLimit(version string) *semver.Version {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return nil
+	}
+
+	switch strings.Count(version, ".") {
+	case 0, 1:
+		limit := v.IncMajor()
+		return &limit
+	default:
+		limit := v.IncMinor()
+		return &limit
+	}
+}
+
+// isOutdated reports whether latest is newer than the lower bound of the
+// current version constraint.
+func isOutdated(current, latest string) bool {
+	currentVersion := lowerBound(current)
+	if currentVersion == nil {
+		return false
+	}
+
+	latestVersion, err := semver.NewVersion(latest)
+	if err != nil {
+		return false
+	}
+
+	return latestVersion.GreaterThan(currentVersion)
+}
+
+// Update levels, from the least to the most risky.
+const (
+	levelPatch = "patch"
+	levelMinor = "minor"
+	levelMajor = "major"
+)
+
+// levelRank orders the update levels so that they can be compared.
+var levelRank = map[string]int{
+	levelPatch: 1,
+	levelMinor: 2,
+	levelMajor: 3,
+}
+
+// validateLevel checks that level is empty or one of the update levels.
+func validateLevel(level string) error {
+	if level != "" && levelRank[level] == 0 {
+		return fmt.Errorf("unknown level %q, expected major, minor or patch", level)
+	}
+	return nil
+}
+
+// atLeastLevel reports whether an update of the given level is at least as
+// risky as min. Every level, including no update, satisfies an empty min.
+func atLeastLevel(level, min string) bool {
+	return levelRank[level] >= levelRank[min]
+}
+
+// levelThreshold is the value of a flag that may be given bare, meaning any
+// update, or with a minimum level, as in --fail-on-outdated=major.
+type levelThreshold struct {
+	Level string
+}
+
+// Set implements flag.Value. A bare flag is set to "true".
+func (t *levelThreshold) Set(value string) error {
+	switch value {
+	case "true":
+		t.Level = levelPatch
+	case "false":
+		t.Level = ""
+	default:
+		if err := validateLevel(value); err != nil {
+			return err
+		}
+		t.Level = value
+	}
+	return nil
+}
+
+// String implements flag.Value.
+func (t *levelThreshold) String() string {
+	return t.Level
+}
+
+// IsBoolFlag lets the flag be given without a value.
+func (t *levelThreshold) IsBoolFlag() bool {
+	return true
+}
+
+// updateLevel classifies the update from the lower bound of the current
+// version constraint to latest as a major, minor or patch update. It returns
+// an empty string when there is no update or either version cannot be parsed.
+func updateLevel(current, latest string) string {
+	currentVersion := lowerBound(current)
+	latestVersion, err := semver.NewVersion(latest)
+	if currentVersion == nil || err != nil || !latestVersion.GreaterThan(currentVersion) {
+		return ""
+	}
+
+	switch {
+	case latestVersion.Major() != currentVersion.Major():
+		return levelMajor
+	case latestVersion.Minor() != currentVersion.Minor():
+		return levelMinor
+	default:
+		return levelPatch
+	}
+}
@@ -0,0 +1,189 @@
+package tfridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/fsnotify/fsnotify"
+	"github.com/urfave/cli/v2"
+)
+
+// watchDebounce is how long to wait for further changes after a file is
+// saved before rescanning, since editors often write a file in several steps.
+const watchDebounce = 200 * time.Millisecond
+
+// releaseCache remembers the versions looked up for each module and provider,
+// so that a scan requests each distinct source only once, and the rescans of
+// --watch do not repeat the same registry requests.
+type releaseCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedReleases
+}
+
+// cachedReleases is the outcome of a lookup.
+type cachedReleases struct {
+	releases  []*semver.Version
+	published []*semver.Version
+	err       error
+}
+
+// newReleaseCache returns an empty releaseCache.
+func newReleaseCache() *releaseCache {
+	return &releaseCache{entries: make(map[string]cachedReleases)}
+}
+
+// lookup returns the cached releases of key, calling fetch to look them up
+// the first time. Failed lookups are cached too, so that a scan does not
+// request a source again, unless they were interrupted. A nil cache always
+// calls fetch.
+func (c *releaseCache) lookup(key string, fetch func() ([]*semver.Version, []*semver.Version, error)) ([]*semver.Version, []*semver.Version, error) {
+	if c == nil {
+		return fetch()
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok {
+		return entry.releases, entry.published, entry.err
+	}
+
+	releases, published, err := fetch()
+	if !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		c.mu.Lock()
+		c.entries[key] = cachedReleases{releases: releases, published: published, err: err}
+		c.mu.Unlock()
+	}
+	return releases, published, err
+}
+
+// lookupReleases returns the releases of key from the client's cache, calling
+// fetch on a miss, and counts the hit or miss in its stats.
+func (c *Client) lookupReleases(key string, fetch func() ([]*semver.Version, []*semver.Version, error)) ([]*semver.Version, []*semver.Version, error) {
+	if c.cache == nil {
+		return fetch()
+	}
+
+	hit := true
+	releases, published, err := c.cache.lookup(key, func() ([]*semver.Version, []*semver.Version, error) {
+		hit = false
+		return fetch()
+	})
+	c.stats.countCache(hit)
+	if hit {
+		c.logger().Debug("cache hit", "key", key)
+	} else {
+		c.logger().Debug("cache miss", "key", key)
+	}
+	return releases, published, err
+}
+
+// forgetFailures drops the cached lookups that failed, other than those that
+// found no versions, so that they are retried.
+func (c *releaseCache) forgetFailures() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, entry := range c.entries {
+		if entry.err != nil && !errors.Is(entry.err, ErrNoVersions) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// runWatch scans opts.rootPath, then scans it again whenever a .tf or .tf.json
+// file below it changes, until ctx is cancelled. Every scan clears the screen
+// and reprints the report, and versions looked up by an earlier scan are
+// reused.
+func runWatch(ctx context.Context, opts options) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %s", err), 1)
+	}
+	defer watcher.Close()
+
+	if err := watchTree(watcher, opts.rootPath); err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %s", err), 1)
+	}
+
+	opts.cache = newReleaseCache()
+	for {
+		if opts.output == "" {
+			fmt.Print("\033[H\033[2J")
+		}
+		err := runScan(ctx, opts)
+		if ctx.Err() != nil {
+			return nil
+		}
+		var exitErr cli.ExitCoder
+		if errors.As(err, &exitErr) {
+			fmt.Fprintln(os.Stderr, exitErr.Error())
+		} else if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Watching %s for changes, press Ctrl-C to stop\n", opts.rootPath)
+
+		if !waitForChange(ctx, watcher) {
+			return nil
+		}
+		opts.cache.forgetFailures()
+	}
+}
+
+// watchTree adds root and every directory below it that a scan would enter
+// to watcher, since fsnotify does not watch subdirectories.
+func watchTree(watcher *fsnotify.Watcher, root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".") && path != root {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+}
+
+// waitForChange blocks until a .tf or .tf.json file changes and no further
+// change follows within watchDebounce. New directories are watched as they
+// are created. It returns false if ctx is cancelled or the watcher stops.
+func waitForChange(ctx context.Context, watcher *fsnotify.Watcher) bool {
+	var settle <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-settle:
+			return true
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return false
+			}
+			logger.Warn("watching for changes failed", "err", err)
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return false
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := watchTree(watcher, event.Name); err != nil {
+						logger.Warn("could not watch directory", "path", event.Name, "err", err)
+					}
+				}
+			}
+			isConfig := strings.HasSuffix(event.Name, ".tf") || strings.HasSuffix(event.Name, ".tf.json")
+			if isConfig && event.Op != fsnotify.Chmod {
+				settle = time.After(watchDebounce)
+			}
+		}
+	}
+}